@@ -0,0 +1,143 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package catalog holds the product catalog, used to show product names and to score the risk of incidents
+// by the value of their items.
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	requestTimeout = 30 * time.Second
+)
+
+var (
+	products      = make(map[string]Product)
+	productsMutex sync.RWMutex
+)
+
+// Product is a single catalog entry
+type Product struct {
+	SKU      string  `json:"sku"`
+	Name     string  `json:"name"`
+	Price    float64 `json:"price"`
+	Category string  `json:"category"`
+	// Risk tier used by risk scoring, such as "high"
+	RiskTier string `json:"risk_tier"`
+}
+
+// Parse parses a JSON list of products
+func Parse(data []byte) (map[string]Product, error) {
+	var list []Product
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]Product, len(list))
+	for i, product := range list {
+		if product.SKU == "" {
+			return nil, fmt.Errorf("product at index %d is missing a sku", i)
+		}
+		if _, ok := result[product.SKU]; ok {
+			return nil, fmt.Errorf("sku %s is defined more than once", product.SKU)
+		}
+		if product.Price < 0 {
+			return nil, fmt.Errorf("price of sku %s must be a value greater than or equal to 0", product.SKU)
+		}
+		result[product.SKU] = product
+	}
+	return result, nil
+}
+
+// Setup loads the catalog from CatalogFile or CatalogURL. A catalog file which fails to load is an error,
+// while a catalog URL which fails to load is only logged, as it is retried by Refresh.
+func Setup() error {
+	switch {
+	case config.AppConfig.CatalogFile != "":
+		data, err := ioutil.ReadFile(config.AppConfig.CatalogFile)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read file %s", config.AppConfig.CatalogFile)
+		}
+		loaded, err := Parse(data)
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse catalog file %s", config.AppConfig.CatalogFile)
+		}
+		Replace(loaded)
+
+	case config.AppConfig.CatalogURL != "":
+		if err := fetch(config.AppConfig.CatalogURL); err != nil {
+			logrus.Errorf("unable to load catalog from %s: %v", config.AppConfig.CatalogURL, err)
+		}
+	}
+	return nil
+}
+
+// Refresh reloads the catalog from CatalogURL every interval, keeping the previous catalog if it fails to load
+func Refresh(interval time.Duration) {
+	if config.AppConfig.CatalogURL == "" {
+		return
+	}
+
+	mRefreshErrors := metrics.GetOrRegisterCounter("loss-prevention-service.Catalog.RefreshError", nil)
+	for range time.Tick(interval) {
+		if err := fetch(config.AppConfig.CatalogURL); err != nil {
+			logrus.Errorf("unable to refresh catalog from %s, keeping the previous catalog: %v", config.AppConfig.CatalogURL, err)
+			mRefreshErrors.Inc(1)
+		}
+	}
+}
+
+func fetch(url string) error {
+	client := http.Client{Timeout: requestTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("Http call returned status code %v: %v  for url: %s", resp.StatusCode, resp.Status, url)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	loaded, err := Parse(body)
+	if err != nil {
+		return err
+	}
+	Replace(loaded)
+	return nil
+}
+
+// Replace replaces the whole catalog
+func Replace(loaded map[string]Product) {
+	productsMutex.Lock()
+	products = loaded
+	productsMutex.Unlock()
+	logrus.Debugf("Loaded %d product(s) into the catalog", len(loaded))
+}
+
+// Lookup returns the catalog entry of a SKU
+func Lookup(sku string) (Product, bool) {
+	productsMutex.RLock()
+	defer productsMutex.RUnlock()
+
+	product, ok := products[sku]
+	return product, ok
+}
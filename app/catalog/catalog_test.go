@@ -0,0 +1,48 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package catalog
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	products, err := Parse([]byte(`[
+		{"sku": "012345678905", "name": "Leather Jacket", "price": 249.99, "category": "outerwear", "risk_tier": "high"},
+		{"sku": "012345678912", "name": "Socks", "price": 4.99}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product := products["012345678905"]; product.Name != "Leather Jacket" || product.Price != 249.99 || product.RiskTier != "high" {
+		t.Errorf("unexpected product %+v", product)
+	}
+
+	invalid := map[string]string{
+		"not a list":     `{"sku": "1"}`,
+		"missing sku":    `[{"name": "Socks"}]`,
+		"duplicate sku":  `[{"sku": "1"}, {"sku": "1"}]`,
+		"negative price": `[{"sku": "1", "price": -1}]`,
+	}
+	for name, data := range invalid {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	Replace(map[string]Product{"1": {SKU: "1", Name: "Socks"}})
+	defer Replace(map[string]Product{})
+
+	if product, ok := Lookup("1"); !ok || product.Name != "Socks" {
+		t.Errorf("expected Socks, but got %+v, %v", product, ok)
+	}
+	if _, ok := Lookup("2"); ok {
+		t.Error("expected an unknown sku to not be found")
+	}
+}
@@ -7,10 +7,13 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/expression"
 	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/configuration"
+	"io/ioutil"
 	"regexp"
 	"strconv"
 	"strings"
@@ -18,41 +21,179 @@ import (
 
 type (
 	variables struct {
-		ServiceName, LoggingLevel, Port                             string
-		TelemetryEndpoint, TelemetryDataStoreName                   string
-		VideoUrlBase, CoreCommandUrl                                string
-		VideoDevice                                                 string
-		LiveView, FullscreenView, ShowVideoDebugStats               bool
-		RecordingDuration                                           int
-		VideoResolutionWidth, VideoResolutionHeight                 int
-		VideoOutputFps                                              int
-		VideoOutputCodec, VideoOutputExtension                      string
-		VideoCaptureFOURCC                                          string
-		VideoCaptureBufferSize                                      int
-		EPCFilter, SKUFilter                                        string
-		EPCFilterRegex, SKUFilterRegex                              *regexp.Regexp
-		ImageProcessScale                                           int
-		SaveObjectDetectionsToDisk                                  bool
-		ThumbnailHeight                                             int
-		EnableCORS                                                  bool
-		CORSOrigin                                                  string
-		EnableFaceDetection                                         bool
-		FaceDetectionColor                                          float64
-		FaceDetectionXmlFile, FaceDetectionAnnotation               string
-		EnableProfileFaceDetection                                  bool
-		ProfileFaceDetectionColor                                   float64
-		ProfileFaceDetectionXmlFile, ProfileFaceDetectionAnnotation string
-		EnableUpperBodyDetection                                    bool
-		UpperBodyDetectionColor                                     float64
-		UpperBodyDetectionXmlFile, UpperBodyDetectionAnnotation     string
-		EnableFullBodyDetection                                     bool
-		FullBodyDetectionColor                                      float64
-		FullBodyDetectionXmlFile, FullBodyDetectionAnnotation       string
-		EnableEyeDetection                                          bool
-		EyeDetectionColor                                           float64
-		EyeDetectionXmlFile, EyeDetectionAnnotation                 string
-		NotificationServiceURL, EmailSubscribers                    string
+		ServiceName, LoggingLevel, Port                 string
+		TelemetryEndpoint, TelemetryDataStoreName       string
+		VideoUrlBase, CoreCommandUrl                    string
+		VideoDevice                                     string
+		LiveView, FullscreenView, ShowVideoDebugStats   bool
+		RecordingDuration, PreRecordingDuration         int
+		MaxRecordingDuration                            int
+		RecordingMode                                   string
+		RecordingQueueSize                              int
+		VideoResolutionWidth, VideoResolutionHeight     int
+		VideoOutputFps                                  int
+		VideoOutputCodec, VideoOutputExtension          string
+		VideoCaptureFOURCC                              string
+		VideoCaptureBufferSize                          int
+		EPCFilter, SKUFilter, GTINFilter                string
+		EPCFilterRegex, SKUFilterRegex, GTINFilterRegex *regexp.Regexp
+		ImageProcessScale                               int
+		SaveObjectDetectionsToDisk                      bool
+		ThumbnailHeight                                 int
+		EnableCORS                                      bool
+		CORSOrigin                                      string
+		DetectionProfilesFile                           string
+		DetectionProfiles                               []DetectionProfile
+		TrackMinOverlap, TrackMaxDistance               float64
+		TrackMaxMissedFrames                            int
+		BestShotsPerTrack                               int
+		NotificationServiceURL, EmailSubscribers        string
+		CamerasFile                                     string
+		IncidentDatabaseFile                            string
+		SaleReconciliationWindow                        int
+		SoldItemAction                                  string
+		EnableFittingRoomAlerts                         bool
+		FittingRoomTimeout                              int
+		FittingRoomMaxVisitAge                          int
+		TriggerCooldown                                 int
+		CooldownOverridesFile                           string
+		CooldownOverrides                               CooldownOverrides
+		SegmentTimeout                                  int
+		MaxTransitionTime                               int
+		RulesFile                                       string
+		Rules                                           []RuleConfig
+		ShadowRulesFile                                 string
+		ShadowRules                                     []RuleConfig
+		DryRun                                          bool
+		DecisionLogSize                                 int
+		FilterListsFile                                 string
+		FilterLists                                     []FilterListConfig
+		FilterListsPollInterval                         int
+		CatalogFile, CatalogURL                         string
+		CatalogRefreshInterval                          int
+		RiskFile                                        string
+		Risk                                            *RiskConfig
+		WatchlistsFile                                  string
+		Watchlists                                      map[string][]string
+		Cameras                                         []CameraConfig
 	}
+
+	// CooldownOverrides replaces the default trigger cooldown (in seconds) for specific SKUs and sensors.
+	// SKU keys may contain '*' wildcards. A matching SKU takes precedence over a matching sensor.
+	CooldownOverrides struct {
+		SKUs    map[string]int `json:"skus"`
+		Sensors map[string]int `json:"sensors"`
+
+		skuRegexes map[string]*regexp.Regexp
+	}
+
+	// CameraConfig maps a single camera to the RSP sensors and/or antenna aliases it covers.
+	// A camera with neither DeviceIds nor Aliases covers every exit sensor.
+	CameraConfig struct {
+		Name        string   `json:"name"`
+		VideoDevice string   `json:"video_device"`
+		DeviceIds   []string `json:"device_ids"`
+		Aliases     []string `json:"aliases"`
+	}
+
+	// RuleConfig decides what happens to a tag matching all of its conditions.
+	// Rules are evaluated in order, and the first matching rule wins.
+	RuleConfig struct {
+		Name       string         `json:"name"`
+		Conditions RuleConditions `json:"conditions"`
+		// One of RuleActionRecord, RuleActionNotify or RuleActionIgnore
+		Action string `json:"action"`
+		// Severity of the notification, CRITICAL or NORMAL
+		Severity string `json:"severity"`
+	}
+
+	// RuleConditions are the conditions of a rule. Every non-empty condition must match,
+	// and list conditions match if any of their values match.
+	RuleConditions struct {
+		Events []string `json:"events"`
+		// Personalities of the sensor the tag was previously read at
+		FromPersonalities    []string `json:"from_personalities"`
+		NotFromPersonalities []string `json:"not_from_personalities"`
+		// Personalities of the sensor the tag is currently read at
+		ToPersonalities    []string `json:"to_personalities"`
+		NotToPersonalities []string `json:"not_to_personalities"`
+		// SKU and EPC wildcard patterns
+		SKUs []string `json:"skus"`
+		EPCs []string `json:"epcs"`
+		// Fields of SGTIN EPCs. GTINs and serials are either wildcard patterns or numeric ranges such as
+		// "00614141000000-00614141999999", company prefixes are wildcard patterns.
+		GTINs           []string `json:"gtins"`
+		CompanyPrefixes []string `json:"company_prefixes"`
+		Serials         []string `json:"serials"`
+		// Names of filter lists, see FilterListConfig
+		Lists      []string `json:"lists"`
+		Facilities []string `json:"facilities"`
+		// Minimum confidence that the tag is actually present
+		MinConfidence float64 `json:"min_confidence"`
+		// Directions inferred from the location history (such as "exit" or "near_door"), see the direction package
+		Directions []string `json:"directions"`
+		// Minimum confidence of the inferred direction
+		MinDirectionConfidence float64 `json:"min_direction_confidence"`
+		// Time of day window in 24 hour HH:MM local time. The window may wrap around midnight.
+		StartTime string `json:"start_time"`
+		EndTime   string `json:"end_time"`
+		// Boolean expression over ExpressionVariables and the Watchlists, see the expression package
+		Expression string `json:"expression"`
+
+		program *expression.Program
+	}
+)
+
+const (
+	// RuleActionRecord records the tag on camera and notifies once the recording is done
+	RuleActionRecord = "record"
+	// RuleActionNotify notifies without recording
+	RuleActionNotify = "notify"
+	// RuleActionIgnore stops evaluating the tag
+	RuleActionIgnore = "ignore"
+)
+
+// ExpressionVariables are the variables available to rule expressions, besides the Watchlists:
+// the tag, its location history, the sensors it moved from and to, its decoded SGTIN (nil if the EPC is not an SGTIN),
+// and the direction inferred from its location history
+var ExpressionVariables = []string{"tag", "history", "from", "to", "sgtin", "direction"}
+
+// DefaultRules trigger a recording when a tag recently moved from inside the store to an exit, and only
+// notify when the move to the exit is ambiguous, such as right after coming in through the same exit
+var DefaultRules = []RuleConfig{
+	{
+		Name: "exit",
+		Conditions: RuleConditions{
+			Events:                 []string{"moved"},
+			Directions:             []string{"exit"},
+			MinDirectionConfidence: 1,
+		},
+		Action:   RuleActionRecord,
+		Severity: "CRITICAL",
+	},
+	{
+		Name: "possible-exit",
+		Conditions: RuleConditions{
+			Events:     []string{"moved"},
+			Directions: []string{"exit"},
+		},
+		Action:   RuleActionNotify,
+		Severity: "NORMAL",
+	},
+}
+
+const (
+	defaultCameraName = "default"
+
+	// RecordingModeExtend extends the recording in progress when another tag triggers on the same camera
+	RecordingModeExtend = "extend"
+	// RecordingModeQueue queues up a separate recording when another tag triggers on the same camera
+	RecordingModeQueue = "queue"
+
+	// SoldItemActionSuppress does not trigger on exiting tags which were recently sold
+	SoldItemActionSuppress = "suppress"
+	// SoldItemActionFlag triggers on exiting tags which were recently sold, but flags them as low risk
+	SoldItemActionFlag = "flag"
 )
 
 // AppConfig exports all config variables
@@ -82,6 +223,19 @@ func InitConfig() error {
 	AppConfig.ShowVideoDebugStats = getOrDefaultBool(config, "showVideoDebugStats", false)
 	AppConfig.SaveObjectDetectionsToDisk = getOrDefaultBool(config, "saveObjectDetectionsToDisk", true)
 	AppConfig.RecordingDuration = getOrDefaultInt(config, "recordingDuration", 15)
+	AppConfig.PreRecordingDuration = getOrDefaultInt(config, "preRecordingDuration", 5)
+	if AppConfig.PreRecordingDuration < 0 {
+		return fmt.Errorf("preRecordingDuration must be a value greater than or equal to 0")
+	}
+	AppConfig.MaxRecordingDuration = getOrDefaultInt(config, "maxRecordingDuration", 60)
+	AppConfig.RecordingMode = getOrDefaultString(config, "recordingMode", RecordingModeExtend)
+	if AppConfig.RecordingMode != RecordingModeExtend && AppConfig.RecordingMode != RecordingModeQueue {
+		return fmt.Errorf("recordingMode must be either '%s' or '%s'", RecordingModeExtend, RecordingModeQueue)
+	}
+	AppConfig.RecordingQueueSize = getOrDefaultInt(config, "recordingQueueSize", 5)
+	if AppConfig.RecordingQueueSize < 1 {
+		return fmt.Errorf("recordingQueueSize must be a value greater than 0")
+	}
 	AppConfig.VideoResolutionWidth = getOrDefaultInt(config, "videoResolutionWidth", 1280)
 	AppConfig.VideoResolutionHeight = getOrDefaultInt(config, "videoResolutionHeight", 720)
 	AppConfig.ImageProcessScale = getOrDefaultInt(config, "imageProcessScale", 2)
@@ -104,13 +258,73 @@ func InitConfig() error {
 		return errors.Wrapf(err, "Unable to load config variables: %s", err.Error())
 	}
 
+	AppConfig.CamerasFile = getOrDefaultString(config, "camerasFile", "")
 	if AppConfig.VideoDevice, err = config.GetString("ipCameraStreamUrl"); err != nil || AppConfig.VideoDevice == "" || getOrDefaultBool(config, "forceUsbCamera", false) {
 		if device, err := config.GetInt("usbCameraDeviceIndex"); err == nil && device >= 0 {
 			AppConfig.VideoDevice = strconv.Itoa(device)
-		} else {
+		} else if AppConfig.CamerasFile == "" {
+			// a video device is only required when there is no camera registry
 			return errors.Wrapf(err, "Unable to load config variables: %v", err)
 		}
 	}
+	if err = loadCameras(); err != nil {
+		return errors.Wrapf(err, "Unable to load config variables: %v", err)
+	}
+
+	AppConfig.IncidentDatabaseFile = getOrDefaultString(config, "incidentDatabaseFile", "/incidents/incidents.db")
+
+	AppConfig.SaleReconciliationWindow = getOrDefaultInt(config, "saleReconciliationWindow", 300)
+	if AppConfig.SaleReconciliationWindow < 0 {
+		return fmt.Errorf("saleReconciliationWindow must be a value greater than or equal to 0")
+	}
+	AppConfig.SoldItemAction = getOrDefaultString(config, "soldItemAction", SoldItemActionSuppress)
+	if AppConfig.SoldItemAction != SoldItemActionSuppress && AppConfig.SoldItemAction != SoldItemActionFlag {
+		return fmt.Errorf("soldItemAction must be either '%s' or '%s'", SoldItemActionSuppress, SoldItemActionFlag)
+	}
+
+	AppConfig.EnableFittingRoomAlerts = getOrDefaultBool(config, "enableFittingRoomAlerts", false)
+	AppConfig.FittingRoomTimeout = getOrDefaultInt(config, "fittingRoomTimeout", 900)
+	if AppConfig.FittingRoomTimeout < 1 {
+		return fmt.Errorf("fittingRoomTimeout must be a value greater than 0")
+	}
+	AppConfig.FittingRoomMaxVisitAge = getOrDefaultInt(config, "fittingRoomMaxVisitAge", 14400)
+	if AppConfig.FittingRoomMaxVisitAge <= AppConfig.FittingRoomTimeout {
+		return fmt.Errorf("fittingRoomMaxVisitAge must be a value greater than fittingRoomTimeout")
+	}
+
+	AppConfig.TriggerCooldown = getOrDefaultInt(config, "triggerCooldown", 60)
+	if AppConfig.TriggerCooldown < 0 {
+		return fmt.Errorf("triggerCooldown must be a value greater than or equal to 0")
+	}
+	AppConfig.CooldownOverridesFile = getOrDefaultString(config, "cooldownOverridesFile", "")
+	if err = loadCooldownOverrides(); err != nil {
+		return errors.Wrapf(err, "Unable to load config variables: %v", err)
+	}
+
+	AppConfig.SegmentTimeout = getOrDefaultInt(config, "segmentTimeout", 10)
+	if AppConfig.SegmentTimeout < 1 {
+		return fmt.Errorf("segmentTimeout must be a value greater than 0")
+	}
+
+	AppConfig.MaxTransitionTime = getOrDefaultInt(config, "maxTransitionTime", 60)
+	if AppConfig.MaxTransitionTime < 1 {
+		return fmt.Errorf("maxTransitionTime must be a value greater than 0")
+	}
+
+	AppConfig.WatchlistsFile = getOrDefaultString(config, "watchlistsFile", "")
+	AppConfig.RulesFile = getOrDefaultString(config, "rulesFile", "")
+	AppConfig.ShadowRulesFile = getOrDefaultString(config, "shadowRulesFile", "")
+	if err = loadRules(); err != nil {
+		return errors.Wrapf(err, "Unable to load config variables: %v", err)
+	}
+
+	AppConfig.DryRun = getOrDefaultBool(config, "dryRun", false)
+
+	AppConfig.DecisionLogSize = getOrDefaultInt(config, "decisionLogSize", 10000)
+	if AppConfig.DecisionLogSize < 0 {
+		return fmt.Errorf("decisionLogSize must be a value greater than or equal to 0")
+	}
+
 	AppConfig.ThumbnailHeight = getOrDefaultInt(config, "thumbnailHeight", 200)
 	AppConfig.EnableCORS = getOrDefaultBool(config, "enableCORS", true)
 	AppConfig.CORSOrigin = getOrDefaultString(config, "corsOrigin", "*")
@@ -125,30 +339,54 @@ func InitConfig() error {
 		return errors.Wrapf(err, "Unable to load config variables: %v", err)
 	}
 
-	AppConfig.EnableFaceDetection = getOrDefaultBool(config, "enableFaceDetection", true)
-	AppConfig.FaceDetectionColor = getOrDefaultFloat64(config, "faceDetectionColor", 0)
-	AppConfig.FaceDetectionXmlFile = getOrDefaultString(config, "faceDetectionXmlFile", "haarcascade_frontalface_default.xml")
-	AppConfig.FaceDetectionAnnotation = getOrDefaultString(config, "faceDetectionAnnotation", "")
-
-	AppConfig.EnableProfileFaceDetection = getOrDefaultBool(config, "enableProfileFaceDetection", true)
-	AppConfig.ProfileFaceDetectionColor = getOrDefaultFloat64(config, "profileFaceDetectionColor", 0)
-	AppConfig.ProfileFaceDetectionXmlFile = getOrDefaultString(config, "profileFaceDetectionXmlFile", "haarcascade_profileface.xml")
-	AppConfig.ProfileFaceDetectionAnnotation = getOrDefaultString(config, "profileFaceDetectionAnnotation", "")
+	AppConfig.GTINFilter = getOrDefaultString(config, "gtinFilter", "*")
+	if AppConfig.GTINFilterRegex, err = regexp.Compile(filterToRegexPattern(AppConfig.GTINFilter)); err != nil {
+		return errors.Wrapf(err, "Unable to load config variables: %v", err)
+	}
 
-	AppConfig.EnableUpperBodyDetection = getOrDefaultBool(config, "enableUpperBodyDetection", true)
-	AppConfig.UpperBodyDetectionColor = getOrDefaultFloat64(config, "upperBodyDetectionColor", 0)
-	AppConfig.UpperBodyDetectionXmlFile = getOrDefaultString(config, "upperBodyDetectionXmlFile", "haarcascade_upperbody.xml")
-	AppConfig.UpperBodyDetectionAnnotation = getOrDefaultString(config, "upperBodyDetectionAnnotation", "")
+	AppConfig.FilterListsFile = getOrDefaultString(config, "filterListsFile", "")
+	if err = loadFilterLists(); err != nil {
+		return errors.Wrapf(err, "Unable to load config variables: %v", err)
+	}
+	AppConfig.FilterListsPollInterval = getOrDefaultInt(config, "filterListsPollInterval", 30)
+	if AppConfig.FilterListsPollInterval < 1 {
+		return fmt.Errorf("filterListsPollInterval must be a value greater than 0")
+	}
 
-	AppConfig.EnableFullBodyDetection = getOrDefaultBool(config, "enableFullBodyDetection", true)
-	AppConfig.FullBodyDetectionColor = getOrDefaultFloat64(config, "fullBodyDetectionColor", 0)
-	AppConfig.FullBodyDetectionXmlFile = getOrDefaultString(config, "fullBodyDetectionXmlFile", "haarcascade_fullbody.xml")
-	AppConfig.FullBodyDetectionAnnotation = getOrDefaultString(config, "fullBodyDetectionAnnotation", "")
+	AppConfig.CatalogFile = getOrDefaultString(config, "catalogFile", "")
+	AppConfig.CatalogURL = getOrDefaultString(config, "catalogUrl", "")
+	if AppConfig.CatalogFile != "" && AppConfig.CatalogURL != "" {
+		return fmt.Errorf("only one of catalogFile and catalogUrl can be set")
+	}
+	AppConfig.CatalogRefreshInterval = getOrDefaultInt(config, "catalogRefreshInterval", 3600)
+	if AppConfig.CatalogRefreshInterval < 1 {
+		return fmt.Errorf("catalogRefreshInterval must be a value greater than 0")
+	}
+	AppConfig.RiskFile = getOrDefaultString(config, "riskFile", "")
+	if err = loadRisk(); err != nil {
+		return errors.Wrapf(err, "Unable to load config variables: %v", err)
+	}
 
-	AppConfig.EnableEyeDetection = getOrDefaultBool(config, "enableEyeDetection", false)
-	AppConfig.EyeDetectionColor = getOrDefaultFloat64(config, "eyeDetectionColor", 0)
-	AppConfig.EyeDetectionXmlFile = getOrDefaultString(config, "eyeDetectionXmlFile", "haarcascade_eye.xml")
-	AppConfig.EyeDetectionAnnotation = getOrDefaultString(config, "eyeDetectionAnnotation", "")
+	AppConfig.DetectionProfilesFile = getOrDefaultString(config, "detectionProfilesFile", "")
+	if err = loadDetectionProfiles(); err != nil {
+		return errors.Wrapf(err, "Unable to load config variables: %v", err)
+	}
+	AppConfig.TrackMinOverlap = getOrDefaultFloat64(config, "trackMinOverlap", 0.3)
+	if AppConfig.TrackMinOverlap <= 0 || AppConfig.TrackMinOverlap > 1 {
+		return fmt.Errorf("trackMinOverlap must be a value greater than 0 and at most 1")
+	}
+	AppConfig.TrackMaxDistance = getOrDefaultFloat64(config, "trackMaxDistance", 0.5)
+	if AppConfig.TrackMaxDistance < 0 {
+		return fmt.Errorf("trackMaxDistance must be a value greater than or equal to 0")
+	}
+	AppConfig.TrackMaxMissedFrames = getOrDefaultInt(config, "trackMaxMissedFrames", 10)
+	if AppConfig.TrackMaxMissedFrames < 0 {
+		return fmt.Errorf("trackMaxMissedFrames must be a value greater than or equal to 0")
+	}
+	AppConfig.BestShotsPerTrack = getOrDefaultInt(config, "bestShotsPerTrack", 1)
+	if AppConfig.BestShotsPerTrack < 1 {
+		return fmt.Errorf("bestShotsPerTrack must be a value greater than 0")
+	}
 
 	AppConfig.NotificationServiceURL = getOrDefaultString(config, "notificationServiceURL", "http://edgex-support-notifications:48060")
 	AppConfig.EmailSubscribers = getOrDefaultString(config, "emailSubscribers", "")
@@ -156,6 +394,217 @@ func InitConfig() error {
 	return nil
 }
 
+// loadCameras reads the camera registry from CamerasFile. If no file is configured, a single
+// camera using VideoDevice which covers every exit sensor is used instead.
+func loadCameras() error {
+	if AppConfig.CamerasFile == "" {
+		AppConfig.Cameras = []CameraConfig{{Name: defaultCameraName, VideoDevice: AppConfig.VideoDevice}}
+		return nil
+	}
+
+	if err := loadJSONFile(AppConfig.CamerasFile, &AppConfig.Cameras); err != nil {
+		return err
+	}
+	if len(AppConfig.Cameras) == 0 {
+		return fmt.Errorf("camerasFile %s does not contain any cameras", AppConfig.CamerasFile)
+	}
+
+	names := make(map[string]bool)
+	for i, cam := range AppConfig.Cameras {
+		if cam.Name == "" {
+			return fmt.Errorf("camera at index %d is missing a name", i)
+		}
+		// the camera name is part of the recording folder name, which is delimited by underscores
+		if strings.ContainsAny(cam.Name, "_/") {
+			return fmt.Errorf("camera name %s must not contain '_' or '/'", cam.Name)
+		}
+		if names[cam.Name] {
+			return fmt.Errorf("camera name %s is defined more than once", cam.Name)
+		}
+		names[cam.Name] = true
+
+		if cam.VideoDevice == "" {
+			return fmt.Errorf("camera %s is missing a video_device", cam.Name)
+		}
+	}
+
+	return nil
+}
+
+// loadRules reads the rules from RulesFile, or uses DefaultRules if no file is configured, along with the
+// candidate rules from ShadowRulesFile. Rule expressions are compiled here, the rest of each rule is
+// validated when it is compiled by the rules package.
+func loadRules() error {
+	AppConfig.Watchlists = make(map[string][]string)
+	if AppConfig.WatchlistsFile != "" {
+		if err := loadJSONFile(AppConfig.WatchlistsFile, &AppConfig.Watchlists); err != nil {
+			return err
+		}
+	}
+	for name := range AppConfig.Watchlists {
+		for _, variable := range ExpressionVariables {
+			if name == variable {
+				return fmt.Errorf("watchlist name %s is reserved", name)
+			}
+		}
+	}
+
+	if AppConfig.RulesFile == "" {
+		AppConfig.Rules = DefaultRules
+	} else {
+		var err error
+		if AppConfig.Rules, err = loadRuleFile(AppConfig.RulesFile); err != nil {
+			return err
+		}
+	}
+
+	AppConfig.ShadowRules = nil
+	if AppConfig.ShadowRulesFile != "" {
+		var err error
+		if AppConfig.ShadowRules, err = loadRuleFile(AppConfig.ShadowRulesFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRuleFile reads a list of rules and compiles their expressions
+func loadRuleFile(filename string) ([]RuleConfig, error) {
+	var rules []RuleConfig
+	if err := loadJSONFile(filename, &rules); err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("%s does not contain any rules", filename)
+	}
+
+	for i := range rules {
+		conditions := &rules[i].Conditions
+		if conditions.Expression == "" {
+			continue
+		}
+		program, err := CompileExpression(conditions.Expression)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid expression in rule %s", rules[i].Name)
+		}
+		conditions.program = program
+	}
+	return rules, nil
+}
+
+// Program returns the compiled Expression, or nil if it has not been compiled by the config loader
+func (conditions RuleConditions) Program() *expression.Program {
+	return conditions.program
+}
+
+// CompileExpression compiles a rule expression, which may use the ExpressionVariables and Watchlists
+func CompileExpression(source string) (*expression.Program, error) {
+	variables := append([]string(nil), ExpressionVariables...)
+	for name := range AppConfig.Watchlists {
+		variables = append(variables, name)
+	}
+	return expression.Compile(source, variables)
+}
+
+// CompileFilter compiles a wildcard filter such as "0123*" into a regular expression
+func CompileFilter(filter string) (*regexp.Regexp, error) {
+	return regexp.Compile(filterToRegexPattern(filter))
+}
+
+// loadCooldownOverrides reads the per-SKU and per-sensor trigger cooldowns from CooldownOverridesFile
+func loadCooldownOverrides() error {
+	AppConfig.CooldownOverrides = CooldownOverrides{}
+	if AppConfig.CooldownOverridesFile != "" {
+		if err := loadJSONFile(AppConfig.CooldownOverridesFile, &AppConfig.CooldownOverrides); err != nil {
+			return err
+		}
+	}
+
+	AppConfig.CooldownOverrides.skuRegexes = make(map[string]*regexp.Regexp)
+	for sku, seconds := range AppConfig.CooldownOverrides.SKUs {
+		if seconds < 0 {
+			return fmt.Errorf("cooldown for sku %s must be a value greater than or equal to 0", sku)
+		}
+		regex, err := regexp.Compile(filterToRegexPattern(sku))
+		if err != nil {
+			return err
+		}
+		AppConfig.CooldownOverrides.skuRegexes[sku] = regex
+	}
+	for sensorId, seconds := range AppConfig.CooldownOverrides.Sensors {
+		if seconds < 0 {
+			return fmt.Errorf("cooldown for sensor %s must be a value greater than or equal to 0", sensorId)
+		}
+	}
+
+	return nil
+}
+
+// Cooldown returns the trigger cooldown in seconds for a tag with the given SKU read at the given sensor.
+// If more than one SKU pattern matches, the longest cooldown is used.
+func (overrides CooldownOverrides) Cooldown(sku string, sensorId string, defaultSeconds int) int {
+	seconds, found := 0, false
+	for pattern, regex := range overrides.skuRegexes {
+		if regex.MatchString(sku) && (!found || overrides.SKUs[pattern] > seconds) {
+			seconds, found = overrides.SKUs[pattern], true
+		}
+	}
+	if found {
+		return seconds
+	}
+
+	if seconds, ok := overrides.Sensors[sensorId]; ok {
+		return seconds
+	}
+	return defaultSeconds
+}
+
+// loadJSONFile decodes the contents of a json file into v
+func loadJSONFile(filename string, v interface{}) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read file %s", filename)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.Wrapf(err, "unable to parse json file %s", filename)
+	}
+	return nil
+}
+
+// CompileRangeFilter compiles either a numeric range filter such as "100-199", which matches every number
+// between both ends inclusive, or a wildcard filter such as "0123*"
+func CompileRangeFilter(filter string) (func(value string) bool, error) {
+	if bounds := strings.SplitN(filter, "-", 2); len(bounds) == 2 && isDigits(bounds[0]) && isDigits(bounds[1]) {
+		low, lowErr := strconv.ParseUint(bounds[0], 10, 64)
+		high, highErr := strconv.ParseUint(bounds[1], 10, 64)
+		if lowErr != nil || highErr != nil || low > high {
+			return nil, fmt.Errorf("invalid range %s", filter)
+		}
+		return func(value string) bool {
+			number, err := strconv.ParseUint(value, 10, 64)
+			return err == nil && number >= low && number <= high
+		}, nil
+	}
+
+	regex, err := CompileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return regex.MatchString, nil
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func filterToRegexPattern(filter string) string {
 	return "^" + strings.ReplaceAll(filter, "*", ".*") + "$"
 }
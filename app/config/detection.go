@@ -0,0 +1,219 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package config
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DetectorHaar detects objects with a Haar cascade
+	DetectorHaar = "haar"
+	// DetectorDNN detects objects with a neural network model on the CPU
+	DetectorDNN = "dnn"
+
+	// DNNModelFormatSSD is a model whose output is a list of [image, class, confidence, left, top, right, bottom]
+	DNNModelFormatSSD = "ssd"
+	// DNNModelFormatYOLO is a model whose output is a list of [center x, center y, width, height, objectness, class scores...]
+	DNNModelFormatYOLO = "yolo"
+)
+
+// DetectionProfile is an object detector run on every recorded frame, along with how its detections are
+// drawn and saved
+type DetectionProfile struct {
+	// Name of the detected objects, used in crop filenames such as face.0.jpg. Detections of DNN profiles
+	// are named by their labels instead.
+	Name     string `json:"name"`
+	Disabled bool   `json:"disabled"`
+	// DetectorHaar (default) or DetectorDNN
+	Detector string `json:"detector"`
+	// Haar cascade file in the haarcascades folder, or the DNN model weights
+	ModelFile string `json:"model_file"`
+	// DNN network description, such as a .pbtxt, .prototxt or .cfg file. Not needed by every model format.
+	ConfigFile string `json:"config_file"`
+
+	// Haar cascade parameters. Sizes are fractions of the video resolution. If all of them are 0,
+	// the OpenCV defaults are used.
+	Scale        float64 `json:"scale"`
+	MinNeighbors int     `json:"min_neighbors"`
+	Flags        int     `json:"flags"`
+	MinScaleX    float64 `json:"min_scale_x"`
+	MinScaleY    float64 `json:"min_scale_y"`
+	MaxScaleX    float64 `json:"max_scale_x"`
+	MaxScaleY    float64 `json:"max_scale_y"`
+
+	// DNN parameters. Format is DNNModelFormatSSD (default) or DNNModelFormatYOLO, and Labels are the class
+	// names in the order of the model's class ids.
+	Format        string   `json:"format"`
+	Labels        []string `json:"labels"`
+	InputWidth    int      `json:"input_width"`
+	InputHeight   int      `json:"input_height"`
+	ScaleFactor   float64  `json:"scale_factor"`
+	SwapRB        bool     `json:"swap_rb"`
+	MinConfidence float64  `json:"min_confidence"`
+
+	// Text written above each detection in the live view. DNN profiles default to the label and confidence.
+	Annotation string `json:"annotation"`
+	// Color of the box drawn around each detection, as #RRGGBB
+	Color     string `json:"color"`
+	Thickness int    `json:"thickness"`
+	// Draw a circle instead of a box
+	Circle bool `json:"circle"`
+	// Write a crop of each detection to the recording folder, when saveObjectDetectionsToDisk is enabled
+	SaveCrops bool `json:"save_crops"`
+}
+
+// RGB returns the Color of the profile as 0xRRGGBB, black if no color is set
+func (profile DetectionProfile) RGB() uint32 {
+	rgb, _ := parseColor(profile.Color)
+	return rgb
+}
+
+// DefaultDetectionProfiles are the Haar cascades used when no detectionProfilesFile is configured
+var DefaultDetectionProfiles = []DetectionProfile{
+	{
+		Name: "face", ModelFile: "haarcascade_frontalface_default.xml",
+		Scale: 1.4, MinNeighbors: 4, MinScaleX: 0.05, MinScaleY: 0.05, MaxScaleX: 0.8, MaxScaleY: 0.8,
+		Thickness: 2, SaveCrops: true,
+	},
+	{
+		Name: "profile_face", ModelFile: "haarcascade_profileface.xml",
+		Scale: 1.4, MinNeighbors: 4, MinScaleX: 0.1, MinScaleY: 0.1, MaxScaleX: 0.8, MaxScaleY: 0.8,
+		Thickness: 2, SaveCrops: true,
+	},
+	{
+		Name: "upper_body", ModelFile: "haarcascade_upperbody.xml",
+		Scale: 1.5, MinNeighbors: 3, MinScaleX: 0.1, MinScaleY: 0.1, MaxScaleX: 0.75, MaxScaleY: 0.75,
+		Thickness: 2, SaveCrops: true,
+	},
+	{
+		Name: "full_body", ModelFile: "haarcascade_fullbody.xml",
+		Scale: 1.4, MinNeighbors: 2, MinScaleX: 0.1, MinScaleY: 0.1, MaxScaleX: 0.6, MaxScaleY: 0.8,
+		Thickness: 2, SaveCrops: true,
+	},
+	{
+		// slow, and not very useful
+		Name: "eye", ModelFile: "haarcascade_eye.xml", Disabled: true,
+		Scale: 1.5, MinNeighbors: 5, MinScaleX: 0.01, MinScaleY: 0.01, MaxScaleX: 0.025, MaxScaleY: 0.025,
+		Thickness: 1, Circle: true, SaveCrops: true,
+	},
+}
+
+// loadDetectionProfiles reads the detection profiles from DetectionProfilesFile, or uses
+// DefaultDetectionProfiles if no file is configured
+func loadDetectionProfiles() error {
+	profiles := DefaultDetectionProfiles
+	if AppConfig.DetectionProfilesFile != "" {
+		profiles = nil
+		if err := loadJSONFile(AppConfig.DetectionProfilesFile, &profiles); err != nil {
+			return err
+		}
+	}
+
+	names := make(map[string]bool)
+	AppConfig.DetectionProfiles = make([]DetectionProfile, 0, len(profiles))
+	for i, profile := range profiles {
+		if profile.Name == "" {
+			return fmt.Errorf("detection profile at index %d is missing a name", i)
+		}
+		if names[profile.Name] {
+			return fmt.Errorf("detection profile name %s is defined more than once", profile.Name)
+		}
+		names[profile.Name] = true
+
+		if err := normalizeDetectionProfile(&profile); err != nil {
+			return errors.Wrapf(err, "invalid detection profile %s", profile.Name)
+		}
+		AppConfig.DetectionProfiles = append(AppConfig.DetectionProfiles, profile)
+	}
+	return nil
+}
+
+// normalizeDetectionProfile validates a profile, and fills in the defaults of the values it does not set
+func normalizeDetectionProfile(profile *DetectionProfile) error {
+	if strings.ContainsAny(profile.Name, "./") {
+		return fmt.Errorf("name must not contain '.' or '/'")
+	}
+	if profile.ModelFile == "" {
+		return fmt.Errorf("missing model_file")
+	}
+	if _, err := parseColor(profile.Color); err != nil {
+		return err
+	}
+	if profile.Thickness < 0 {
+		return fmt.Errorf("thickness must be a value greater than or equal to 0")
+	}
+	if profile.Thickness == 0 {
+		profile.Thickness = 2
+	}
+
+	if profile.Detector == "" {
+		profile.Detector = DetectorHaar
+	}
+	switch profile.Detector {
+	case DetectorHaar:
+		if profile.Scale != 0 && profile.Scale <= 1 {
+			return fmt.Errorf("scale must be a value greater than 1")
+		}
+		return nil
+	case DetectorDNN:
+	default:
+		return fmt.Errorf("detector must be either '%s' or '%s'", DetectorHaar, DetectorDNN)
+	}
+
+	for _, label := range profile.Labels {
+		if strings.ContainsAny(label, "./") {
+			return fmt.Errorf("label %s must not contain '.' or '/'", label)
+		}
+	}
+	if profile.Format == "" {
+		profile.Format = DNNModelFormatSSD
+	}
+	if profile.Format != DNNModelFormatSSD && profile.Format != DNNModelFormatYOLO {
+		return fmt.Errorf("format must be either '%s' or '%s'", DNNModelFormatSSD, DNNModelFormatYOLO)
+	}
+	if profile.InputWidth < 0 || profile.InputHeight < 0 {
+		return fmt.Errorf("input_width and input_height must be values greater than 0")
+	}
+	if profile.InputWidth == 0 {
+		profile.InputWidth = 300
+	}
+	if profile.InputHeight == 0 {
+		profile.InputHeight = 300
+	}
+	if profile.ScaleFactor < 0 {
+		return fmt.Errorf("scale_factor must be a value greater than 0")
+	}
+	if profile.ScaleFactor == 0 {
+		profile.ScaleFactor = 1
+	}
+	if profile.MinConfidence < 0 || profile.MinConfidence > 1 {
+		return fmt.Errorf("min_confidence must be between 0 and 1")
+	}
+	if profile.MinConfidence == 0 {
+		profile.MinConfidence = 0.5
+	}
+	return nil
+}
+
+// parseColor parses a #RRGGBB color. An empty color is black.
+func parseColor(value string) (uint32, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if !strings.HasPrefix(value, "#") || len(value) != 7 {
+		return 0, fmt.Errorf("color must be in the format #RRGGBB, but got %q", value)
+	}
+	rgb, err := strconv.ParseUint(value[1:], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("color must be in the format #RRGGBB, but got %q", value)
+	}
+	return uint32(rgb), nil
+}
@@ -0,0 +1,198 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadDetectionProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "detection-profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		profiles string
+		err      string
+		want     []string
+	}{
+		{
+			name:     "valid",
+			profiles: `[{"name": "face", "model_file": "face.xml"}, {"name": "people", "detector": "dnn", "model_file": "people.pb"}]`,
+			want:     []string{"face", "people"},
+		},
+		{
+			name:     "missing name",
+			profiles: `[{"name": "face", "model_file": "face.xml"}, {"model_file": "face.xml"}]`,
+			err:      "detection profile at index 1 is missing a name",
+		},
+		{
+			name:     "duplicate name",
+			profiles: `[{"name": "face", "model_file": "face.xml"}, {"name": "face", "model_file": "profile.xml"}]`,
+			err:      "detection profile name face is defined more than once",
+		},
+		{
+			name:     "invalid profile",
+			profiles: `[{"name": "face"}]`,
+			err:      "invalid detection profile face: missing model_file",
+		},
+		{
+			name:     "invalid json",
+			profiles: `{"name": "face"}`,
+			err:      "unable to parse json file",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(dir, "profiles.json")
+			if err := ioutil.WriteFile(filename, []byte(test.profiles), 0644); err != nil {
+				t.Fatal(err)
+			}
+			AppConfig = variables{DetectionProfilesFile: filename}
+
+			err := loadDetectionProfiles()
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error %q, but got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, profile := range AppConfig.DetectionProfiles {
+				names = append(names, profile.Name)
+			}
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("expected profiles %v, but got %v", test.want, names)
+			}
+		})
+	}
+
+	// without a file the default Haar cascades are used
+	AppConfig = variables{}
+	if err := loadDetectionProfiles(); err != nil {
+		t.Fatal(err)
+	}
+	if len(AppConfig.DetectionProfiles) != len(DefaultDetectionProfiles) {
+		t.Errorf("expected %d default profiles, but got %d", len(DefaultDetectionProfiles), len(AppConfig.DetectionProfiles))
+	}
+}
+
+func TestNormalizeDetectionProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile DetectionProfile
+		err     string
+		want    DetectionProfile
+	}{
+		{
+			name:    "haar defaults",
+			profile: DetectionProfile{Name: "face", ModelFile: "face.xml"},
+			want:    DetectionProfile{Name: "face", ModelFile: "face.xml", Detector: DetectorHaar, Thickness: 2},
+		},
+		{
+			name:    "dnn defaults",
+			profile: DetectionProfile{Name: "people", Detector: DetectorDNN, ModelFile: "people.pb"},
+			want: DetectionProfile{Name: "people", Detector: DetectorDNN, ModelFile: "people.pb", Thickness: 2,
+				Format: DNNModelFormatSSD, InputWidth: 300, InputHeight: 300, ScaleFactor: 1, MinConfidence: 0.5},
+		},
+		{
+			name: "dnn values kept",
+			profile: DetectionProfile{Name: "people", Detector: DetectorDNN, ModelFile: "people.weights", Thickness: 1,
+				Format: DNNModelFormatYOLO, InputWidth: 416, InputHeight: 416, ScaleFactor: 0.00392, MinConfidence: 0.3},
+			want: DetectionProfile{Name: "people", Detector: DetectorDNN, ModelFile: "people.weights", Thickness: 1,
+				Format: DNNModelFormatYOLO, InputWidth: 416, InputHeight: 416, ScaleFactor: 0.00392, MinConfidence: 0.3},
+		},
+		{
+			name:    "name with a dot",
+			profile: DetectionProfile{Name: "face.1", ModelFile: "face.xml"},
+			err:     "name must not contain",
+		},
+		{
+			name:    "bad color",
+			profile: DetectionProfile{Name: "face", ModelFile: "face.xml", Color: "red"},
+			err:     "color must be in the format #RRGGBB",
+		},
+		{
+			name:    "unknown detector",
+			profile: DetectionProfile{Name: "face", ModelFile: "face.xml", Detector: "hog"},
+			err:     "detector must be either",
+		},
+		{
+			name:    "unknown format",
+			profile: DetectionProfile{Name: "people", Detector: DetectorDNN, ModelFile: "people.pb", Format: "rcnn"},
+			err:     "format must be either",
+		},
+		{
+			name:    "haar scale too small",
+			profile: DetectionProfile{Name: "face", ModelFile: "face.xml", Scale: 1},
+			err:     "scale must be a value greater than 1",
+		},
+		{
+			name:    "min confidence out of range",
+			profile: DetectionProfile{Name: "people", Detector: DetectorDNN, ModelFile: "people.pb", MinConfidence: 1.5},
+			err:     "min_confidence must be between 0 and 1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			profile := test.profile
+			err := normalizeDetectionProfile(&profile)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error %q, but got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(profile, test.want) {
+				t.Errorf("expected %+v, but got %+v", test.want, profile)
+			}
+		})
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  uint32
+		valid bool
+	}{
+		{"", 0, true},
+		{"#ff0000", 0xff0000, true},
+		{"#00FF7f", 0x00ff7f, true},
+		{"ff0000", 0, false},
+		{"#fff", 0, false},
+		{"#gg0000", 0, false},
+	}
+
+	for _, test := range tests {
+		rgb, err := parseColor(test.value)
+		if test.valid != (err == nil) {
+			t.Errorf("parseColor(%q): expected valid to be %v, but got error %v", test.value, test.valid, err)
+			continue
+		}
+		if rgb != test.want {
+			t.Errorf("parseColor(%q): expected %06x, but got %06x", test.value, test.want, rgb)
+		}
+	}
+}
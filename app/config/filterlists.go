@@ -0,0 +1,170 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package config
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// FilterListInclude lists are the only tags which trigger, once at least one include list is configured
+	FilterListInclude = "include"
+	// FilterListExclude lists never trigger, such as items for store use
+	FilterListExclude = "exclude"
+	// FilterListWatch lists do not filter anything, but are reported and can be used by rules, such as high value items
+	FilterListWatch = "watch"
+)
+
+// FilterListConfig is a named list of SKU, EPC and GTIN patterns. SKU and EPC patterns are wildcard patterns,
+// and GTIN patterns are wildcard patterns or numeric ranges.
+type FilterListConfig struct {
+	Name string `json:"name"`
+	// One of FilterListInclude, FilterListExclude or FilterListWatch
+	Type string `json:"type"`
+	// Optional CSV or JSON file with more patterns, relative to the filter lists file. Once loaded,
+	// this is the path of the file, and its patterns are added to the list.
+	Source string   `json:"source"`
+	SKUs   []string `json:"skus"`
+	EPCs   []string `json:"epcs"`
+	GTINs  []string `json:"gtins"`
+}
+
+// filterListSource is the format of a JSON filter list source
+type filterListSource struct {
+	SKUs  []string `json:"skus"`
+	EPCs  []string `json:"epcs"`
+	GTINs []string `json:"gtins"`
+}
+
+// loadFilterLists reads and validates the lists from FilterListsFile
+func loadFilterLists() error {
+	AppConfig.FilterLists = nil
+	if AppConfig.FilterListsFile == "" {
+		return nil
+	}
+
+	var err error
+	AppConfig.FilterLists, err = LoadFilterLists(AppConfig.FilterListsFile)
+	return err
+}
+
+// LoadFilterLists reads a JSON file of filter lists along with each list's source, and validates every pattern
+func LoadFilterLists(filename string) ([]FilterListConfig, error) {
+	var lists []FilterListConfig
+	if err := loadJSONFile(filename, &lists); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for i := range lists {
+		list := &lists[i]
+		if list.Name == "" {
+			return nil, fmt.Errorf("filter list at index %d is missing a name", i)
+		}
+		if names[list.Name] {
+			return nil, fmt.Errorf("filter list name %s is defined more than once", list.Name)
+		}
+		names[list.Name] = true
+
+		switch list.Type {
+		case FilterListInclude, FilterListExclude, FilterListWatch:
+		default:
+			return nil, fmt.Errorf("type of filter list %s must be one of %s, %s or %s, but got %q",
+				list.Name, FilterListInclude, FilterListExclude, FilterListWatch, list.Type)
+		}
+
+		if list.Source != "" {
+			if !filepath.IsAbs(list.Source) {
+				list.Source = filepath.Join(filepath.Dir(filename), list.Source)
+			}
+			if err := loadFilterListSource(list); err != nil {
+				return nil, errors.Wrapf(err, "invalid source of filter list %s", list.Name)
+			}
+		}
+
+		if err := validateFilterList(list); err != nil {
+			return nil, errors.Wrapf(err, "invalid filter list %s", list.Name)
+		}
+	}
+	return lists, nil
+}
+
+// loadFilterListSource adds the patterns from a list's source file. JSON files have the same skus, epcs and gtins
+// fields as a list, and CSV files have a field (sku, epc or gtin) and a pattern on each line.
+func loadFilterListSource(list *FilterListConfig) error {
+	if strings.EqualFold(filepath.Ext(list.Source), ".json") {
+		var source filterListSource
+		if err := loadJSONFile(list.Source, &source); err != nil {
+			return err
+		}
+		list.SKUs = append(list.SKUs, source.SKUs...)
+		list.EPCs = append(list.EPCs, source.EPCs...)
+		list.GTINs = append(list.GTINs, source.GTINs...)
+		return nil
+	}
+
+	file, err := os.Open(list.Source)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read file %s", list.Source)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse csv file %s", list.Source)
+	}
+
+	for i, record := range records {
+		field, pattern := strings.ToLower(strings.TrimSpace(record[0])), strings.TrimSpace(record[1])
+		if i == 0 && field == "field" {
+			// header
+			continue
+		}
+		switch field {
+		case "sku":
+			list.SKUs = append(list.SKUs, pattern)
+		case "epc":
+			list.EPCs = append(list.EPCs, pattern)
+		case "gtin":
+			list.GTINs = append(list.GTINs, pattern)
+		default:
+			return fmt.Errorf("line %d of %s has an unknown field %s, must be sku, epc or gtin", i+1, list.Source, record[0])
+		}
+	}
+	return nil
+}
+
+func validateFilterList(list *FilterListConfig) error {
+	for _, patterns := range [][]string{list.SKUs, list.EPCs} {
+		for _, pattern := range patterns {
+			if pattern == "" {
+				return fmt.Errorf("patterns must not be empty")
+			}
+			if _, err := CompileFilter(pattern); err != nil {
+				return err
+			}
+		}
+	}
+	for _, pattern := range list.GTINs {
+		if pattern == "" {
+			return fmt.Errorf("patterns must not be empty")
+		}
+		if _, err := CompileRangeFilter(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package config
+
+import (
+	"fmt"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/timeofday"
+)
+
+// RiskConfig scores every incident from the value and risk tier of its items (from the product catalog),
+// the number of items, and the time of day. The score decides the notification severity, whether the
+// incident is recorded at all, and for how long.
+type RiskConfig struct {
+	// Points per unit of price of every item
+	ValueWeight float64 `json:"value_weight"`
+	// Points per item in the incident
+	QuantityWeight float64 `json:"quantity_weight"`
+	// Points per item of each risk tier, such as {"high": 30}
+	Tiers map[string]float64 `json:"tiers"`
+	// Points added when the incident happens within a time of day window
+	Hours []RiskHours `json:"hours"`
+	// Incidents scoring at least CriticalScore are CRITICAL, the rest are NORMAL
+	CriticalScore float64 `json:"critical_score"`
+	// Incidents scoring below RecordScore are notified without recording
+	RecordScore float64 `json:"record_score"`
+	// Recording durations by score, replacing RecordingDuration for incidents scoring at least MinScore
+	RecordingDurations []RiskRecordingDuration `json:"recording_durations"`
+}
+
+// RiskHours adds points to incidents within a time of day window in 24 hour HH:MM local time,
+// which may wrap around midnight
+type RiskHours struct {
+	StartTime string  `json:"start_time"`
+	EndTime   string  `json:"end_time"`
+	Points    float64 `json:"points"`
+}
+
+// RiskRecordingDuration is the recording duration in seconds of incidents scoring at least MinScore
+type RiskRecordingDuration struct {
+	MinScore float64 `json:"min_score"`
+	Seconds  int     `json:"seconds"`
+}
+
+// loadRisk reads the risk scoring configuration from RiskFile. Risk scoring is disabled if no file is configured.
+func loadRisk() error {
+	AppConfig.Risk = nil
+	if AppConfig.RiskFile == "" {
+		return nil
+	}
+
+	risk := new(RiskConfig)
+	if err := loadJSONFile(AppConfig.RiskFile, risk); err != nil {
+		return err
+	}
+
+	for _, hours := range risk.Hours {
+		if _, err := timeofday.ParseWindow(hours.StartTime, hours.EndTime); err != nil {
+			return fmt.Errorf("invalid risk hours: %v", err)
+		}
+	}
+	for _, duration := range risk.RecordingDurations {
+		if duration.Seconds < 1 {
+			return fmt.Errorf("risk recording duration for min_score %v must be a value greater than 0", duration.MinScore)
+		}
+	}
+
+	AppConfig.Risk = risk
+	return nil
+}
@@ -0,0 +1,80 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package cooldown
+
+import (
+	"sync"
+)
+
+const (
+	// how often expired entries are pruned, in milliseconds
+	pruneInterval = 60 * 1000
+)
+
+var (
+	entries   = make(map[string]*Entry)
+	mutex     sync.Mutex
+	lastPrune int64
+)
+
+// Entry is an EPC which recently triggered, along with the incidents it triggered
+type Entry struct {
+	EPC string `json:"epc"`
+	// Ids of the incidents the EPC was added to
+	Incidents []string `json:"incidents"`
+	// Time the cooldown ends in milliseconds epoch
+	Expires int64 `json:"expires"`
+	// Number of repeated triggers that were suppressed
+	Repeats int `json:"repeats"`
+}
+
+// Start puts an EPC into cooldown for duration milliseconds after it triggered the given incidents
+func Start(epc string, incidents []string, now int64, duration int64) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	entries[epc] = &Entry{
+		EPC:       epc,
+		Incidents: incidents,
+		Expires:   now + duration,
+	}
+
+	if now-lastPrune > pruneInterval {
+		lastPrune = now
+		for key, entry := range entries {
+			if now >= entry.Expires {
+				delete(entries, key)
+			}
+		}
+	}
+}
+
+// Active returns the cooldown entry of an EPC if it is still cooling down, without counting a repeat
+func Active(epc string, now int64) (Entry, bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	entry, ok := entries[epc]
+	if !ok || now >= entry.Expires {
+		return Entry{}, false
+	}
+	return *entry, true
+}
+
+// Suppress returns the cooldown entry of an EPC if it is still cooling down, counting the suppressed repeat
+func Suppress(epc string, now int64) (Entry, bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	entry, ok := entries[epc]
+	if !ok || now >= entry.Expires {
+		return Entry{}, false
+	}
+
+	entry.Repeats++
+	return *entry, true
+}
@@ -0,0 +1,58 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package cooldown
+
+import (
+	"testing"
+)
+
+func TestSuppress(t *testing.T) {
+	Start("3014AA", []string{"incident-1"}, 1000, 500)
+
+	if _, ok := Suppress("3014BB", 1100); ok {
+		t.Error("Expected an EPC that never triggered to not be suppressed")
+	}
+
+	for i := 1; i <= 2; i++ {
+		entry, ok := Suppress("3014AA", 1100)
+		if !ok {
+			t.Fatal("Expected 3014AA to be suppressed")
+		}
+		if entry.Repeats != i {
+			t.Errorf("Expected %d repeats, but got %d", i, entry.Repeats)
+		}
+		if len(entry.Incidents) != 1 || entry.Incidents[0] != "incident-1" {
+			t.Errorf("Expected incident-1, but got %v", entry.Incidents)
+		}
+	}
+
+	if entry, ok := Suppress("3014AA", 1499); !ok || entry.Repeats != 3 {
+		t.Errorf("Expected 3014AA to still be suppressed with 3 repeats, but got %+v, %v", entry, ok)
+	}
+
+	if _, ok := Suppress("3014AA", 1500); ok {
+		t.Error("Expected 3014AA to no longer be suppressed once the cooldown expired")
+	}
+}
+
+func TestActive(t *testing.T) {
+	Start("3014CC", []string{"incident-2"}, 1000, 500)
+
+	for i := 0; i < 2; i++ {
+		entry, ok := Active("3014CC", 1100)
+		if !ok {
+			t.Fatal("Expected 3014CC to be cooling down")
+		}
+		if entry.Repeats != 0 {
+			t.Errorf("Expected Active to not count repeats, but got %d", entry.Repeats)
+		}
+	}
+
+	if _, ok := Active("3014CC", 1500); ok {
+		t.Error("Expected 3014CC to no longer be cooling down once the cooldown expired")
+	}
+}
@@ -0,0 +1,139 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package decision
+
+import (
+	"fmt"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/incident"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/direction"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+// Stage is the step of the trigger logic which decided what happened to a tag
+type Stage string
+
+const (
+	StageValidation      Stage = "validation"
+	StageLocationHistory Stage = "location_history"
+	StageSKUFilter       Stage = "sku_filter"
+	StageEPCFilter       Stage = "epc_filter"
+	StageGTINFilter      Stage = "gtin_filter"
+	StageFilterList      Stage = "filter_list"
+	StageRules           Stage = "rules"
+	StageCooldown        Stage = "cooldown"
+	StageSold            Stage = "sold"
+	StageCamera          Stage = "camera"
+	// StageTriggered is used for tags which made it through every step
+	StageTriggered Stage = "triggered"
+)
+
+var (
+	trace      = newLog(0)
+	traceMutex sync.Mutex
+)
+
+// Decision is the outcome of evaluating a single tag
+type Decision struct {
+	EPC       string `json:"epc"`
+	ProductID string `json:"product_id"`
+	// GTIN-14 of SGTIN EPCs, empty for any other EPC
+	GTIN  string `json:"gtin,omitempty"`
+	Event string `json:"event"`
+	// Time the tag was evaluated in milliseconds epoch
+	Timestamp int64 `json:"timestamp"`
+	// Antenna alias the tag was read at
+	Location string `json:"location"`
+	// Sensors the tag moved between, with their personalities. Empty if unknown.
+	Sensor              string `json:"sensor"`
+	SensorPersonality   string `json:"sensor_personality"`
+	PreviousSensor      string `json:"previous_sensor"`
+	PreviousPersonality string `json:"previous_personality"`
+	// Direction inferred from the whole location history
+	Direction direction.Inference `json:"direction"`
+	// Names of the filter lists the tag matched
+	Lists []string `json:"lists,omitempty"`
+	// Step which accepted or rejected the tag
+	Stage Stage `json:"stage"`
+	// Rule the tag matched, if it got that far
+	Rule   string `json:"rule,omitempty"`
+	Action string `json:"action,omitempty"`
+	// Triggered is true if the tag was (or in dry run mode, would have been) recorded or notified
+	Triggered bool     `json:"triggered"`
+	Reason    string   `json:"reason"`
+	Cameras   []string `json:"cameras,omitempty"`
+	DryRun    bool     `json:"dry_run,omitempty"`
+
+	// Risk score of the incident the tag was added to, if risk scoring is configured
+	Risk *incident.Risk `json:"risk,omitempty"`
+}
+
+// Reject marks the decision as rejected at the given stage, and logs the reason at debug level
+func (decision *Decision) Reject(stage Stage, format string, args ...interface{}) {
+	decision.Stage = stage
+	decision.Triggered = false
+	decision.Reason = fmt.Sprintf(format, args...)
+	logrus.Debugf("skipping tag: epc: %s (sku: %s): %s", decision.EPC, decision.ProductID, decision.Reason)
+}
+
+// Accept marks the decision as triggered, and logs the reason at debug level
+func (decision *Decision) Accept(format string, args ...interface{}) {
+	decision.Stage = StageTriggered
+	decision.Triggered = true
+	decision.Reason = fmt.Sprintf(format, args...)
+	logrus.Debugf("triggering on tag: epc: %s (sku: %s): %s", decision.EPC, decision.ProductID, decision.Reason)
+}
+
+// decisionLog is a fixed size ring buffer of the most recent decisions
+type decisionLog struct {
+	decisions []Decision
+	next      int
+	count     int
+}
+
+func newLog(size int) *decisionLog {
+	return &decisionLog{decisions: make([]Decision, size)}
+}
+
+// Setup resizes the decision log to hold size decisions, dropping every decision recorded so far.
+// A size of 0 turns off the decision log.
+func Setup(size int) {
+	traceMutex.Lock()
+	trace = newLog(size)
+	traceMutex.Unlock()
+}
+
+// Record adds a decision to the log, overwriting the oldest decision once the log is full
+func Record(decision Decision) {
+	traceMutex.Lock()
+	defer traceMutex.Unlock()
+
+	if len(trace.decisions) == 0 {
+		return
+	}
+	trace.decisions[trace.next] = decision
+	trace.next = (trace.next + 1) % len(trace.decisions)
+	if trace.count < len(trace.decisions) {
+		trace.count++
+	}
+}
+
+// Query returns up to limit of the most recent decisions, newest first. If epc is not empty,
+// only decisions for that EPC are returned.
+func Query(epc string, limit int) []Decision {
+	traceMutex.Lock()
+	defer traceMutex.Unlock()
+
+	results := []Decision{}
+	for i := 0; i < trace.count && len(results) < limit; i++ {
+		decision := trace.decisions[(trace.next-1-i+len(trace.decisions))%len(trace.decisions)]
+		if epc == "" || decision.EPC == epc {
+			results = append(results, decision)
+		}
+	}
+	return results
+}
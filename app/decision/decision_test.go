@@ -0,0 +1,63 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package decision
+
+import (
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	Setup(3)
+	defer Setup(0)
+
+	for i, epc := range []string{"a", "b", "a", "c", "a"} {
+		Record(Decision{EPC: epc, Timestamp: int64(i)})
+	}
+
+	tests := []struct {
+		name  string
+		epc   string
+		limit int
+		want  []int64
+	}{
+		{"all, oldest overwritten", "", 10, []int64{4, 3, 2}},
+		{"limit", "", 2, []int64{4, 3}},
+		{"epc", "a", 10, []int64{4, 2}},
+		{"unknown epc", "d", 10, []int64{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results := Query(test.epc, test.limit)
+			if len(results) != len(test.want) {
+				t.Fatalf("expected %d decisions, but got %d: %+v", len(test.want), len(results), results)
+			}
+			for i, result := range results {
+				if result.Timestamp != test.want[i] {
+					t.Errorf("expected decision %d to have timestamp %d, but got %d", i, test.want[i], result.Timestamp)
+				}
+			}
+		})
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	Setup(0)
+	Record(Decision{EPC: "a"})
+	if results := Query("", 10); len(results) != 0 {
+		t.Errorf("expected no decisions, but got %+v", results)
+	}
+}
+
+func TestReject(t *testing.T) {
+	d := Decision{EPC: "a"}
+	d.Accept("record by rule %s", "exit")
+	d.Reject(StageCooldown, "repeated trigger")
+	if d.Triggered || d.Stage != StageCooldown || d.Reason != "repeated trigger" {
+		t.Errorf("expected rejected decision, but got %+v", d)
+	}
+}
@@ -0,0 +1,225 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package filterlist matches tags against the include, exclude and watch lists from the filter lists file,
+// and reloads the lists whenever the file or any of the list sources change.
+package filterlist
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/sirupsen/logrus"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var (
+	active      = &Set{}
+	activeMutex sync.RWMutex
+	// modification times of every file the active lists were loaded from, only used by Setup and Watch
+	files map[string]time.Time
+)
+
+// list is a compiled config.FilterListConfig
+type list struct {
+	name  string
+	kind  string
+	skus  []*regexp.Regexp
+	epcs  []*regexp.Regexp
+	gtins []func(string) bool
+}
+
+// Set is every configured filter list, in the order they were configured
+type Set struct {
+	lists      []list
+	hasInclude bool
+}
+
+// Match is the result of matching a tag against every list
+type Match struct {
+	// Names of every list the tag matched, in configuration order
+	Lists []string
+	// Name of the first exclude list the tag matched, if any
+	Excluded string
+	// True if the tag matched an include list, or there are no include lists
+	Included bool
+}
+
+// NewSet compiles the filter lists. The patterns must have been validated by the config package.
+func NewSet(configs []config.FilterListConfig) (*Set, error) {
+	set := &Set{}
+	for _, cfg := range configs {
+		compiled := list{name: cfg.Name, kind: cfg.Type}
+		for _, pattern := range cfg.SKUs {
+			regex, err := config.CompileFilter(pattern)
+			if err != nil {
+				return nil, err
+			}
+			compiled.skus = append(compiled.skus, regex)
+		}
+		for _, pattern := range cfg.EPCs {
+			regex, err := config.CompileFilter(pattern)
+			if err != nil {
+				return nil, err
+			}
+			compiled.epcs = append(compiled.epcs, regex)
+		}
+		for _, pattern := range cfg.GTINs {
+			matches, err := config.CompileRangeFilter(pattern)
+			if err != nil {
+				return nil, err
+			}
+			compiled.gtins = append(compiled.gtins, matches)
+		}
+
+		set.lists = append(set.lists, compiled)
+		if cfg.Type == config.FilterListInclude {
+			set.hasInclude = true
+		}
+	}
+	return set, nil
+}
+
+// Match matches a tag against every list. gtin is empty if the EPC is not an SGTIN.
+func (set *Set) Match(sku string, epc string, gtin string) Match {
+	result := Match{Included: !set.hasInclude}
+	for _, l := range set.lists {
+		if !l.matches(sku, epc, gtin) {
+			continue
+		}
+		result.Lists = append(result.Lists, l.name)
+		switch l.kind {
+		case config.FilterListInclude:
+			result.Included = true
+		case config.FilterListExclude:
+			if result.Excluded == "" {
+				result.Excluded = l.name
+			}
+		}
+	}
+	return result
+}
+
+func (l *list) matches(sku string, epc string, gtin string) bool {
+	for _, regex := range l.skus {
+		if regex.MatchString(sku) {
+			return true
+		}
+	}
+	for _, regex := range l.epcs {
+		if regex.MatchString(epc) {
+			return true
+		}
+	}
+	if gtin == "" {
+		return false
+	}
+	for _, matches := range l.gtins {
+		if matches(gtin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Setup compiles the configured filter lists and makes them the active lists
+func Setup() error {
+	set, err := NewSet(config.AppConfig.FilterLists)
+	if err != nil {
+		return err
+	}
+	files = modTimes(config.AppConfig.FilterListsFile, config.AppConfig.FilterLists)
+
+	activeMutex.Lock()
+	active = set
+	activeMutex.Unlock()
+
+	logrus.Debugf("Configured filter lists: %d", len(set.lists))
+	return nil
+}
+
+// Active returns the active filter lists
+func Active() *Set {
+	activeMutex.RLock()
+	defer activeMutex.RUnlock()
+	return active
+}
+
+// Watch checks the filter lists file and every list source for changes every interval, and reloads the lists
+// when any of them change. Lists which fail to load are logged, and the previous lists are kept.
+func Watch(interval time.Duration) {
+	if config.AppConfig.FilterListsFile == "" {
+		return
+	}
+
+	for range time.Tick(interval) {
+		reload()
+	}
+}
+
+func reload() {
+	mReloadErrors := metrics.GetOrRegisterCounter("loss-prevention-service.FilterLists.ReloadError", nil)
+
+	filename := config.AppConfig.FilterListsFile
+	current := modTimes(filename, config.AppConfig.FilterLists)
+	if !changed(files, current) {
+		return
+	}
+	// a broken file is not reloaded again until it changes
+	files = current
+
+	configs, err := config.LoadFilterLists(filename)
+	if err != nil {
+		logrus.Errorf("unable to reload filter lists, keeping the previous lists: %v", err)
+		mReloadErrors.Inc(1)
+		return
+	}
+	set, err := NewSet(configs)
+	if err != nil {
+		logrus.Errorf("unable to reload filter lists, keeping the previous lists: %v", err)
+		mReloadErrors.Inc(1)
+		return
+	}
+
+	activeMutex.Lock()
+	active = set
+	activeMutex.Unlock()
+
+	// sources may have been added or removed
+	config.AppConfig.FilterLists = configs
+	files = modTimes(filename, configs)
+	logrus.Infof("Reloaded %d filter list(s) from %s", len(configs), filename)
+}
+
+// modTimes returns the modification time of the lists file and every list source. Missing files have a zero time.
+func modTimes(filename string, lists []config.FilterListConfig) map[string]time.Time {
+	files := map[string]time.Time{filename: {}}
+	for _, l := range lists {
+		if l.Source != "" {
+			files[l.Source] = time.Time{}
+		}
+	}
+	for name := range files {
+		if info, err := os.Stat(name); err == nil {
+			files[name] = info.ModTime()
+		}
+	}
+	return files
+}
+
+func changed(previous map[string]time.Time, current map[string]time.Time) bool {
+	if len(previous) != len(current) {
+		return true
+	}
+	for name, modTime := range current {
+		if previousTime, ok := previous[name]; !ok || !previousTime.Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
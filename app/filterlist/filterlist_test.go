@@ -0,0 +1,110 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package filterlist
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	set, err := NewSet([]config.FilterListConfig{
+		{Name: "store-use", Type: config.FilterListExclude, SKUs: []string{"0000*"}},
+		{Name: "high-value", Type: config.FilterListWatch, GTINs: []string{"80614141000000-80614141999999"}},
+		{Name: "apparel", Type: config.FilterListInclude, SKUs: []string{"01*", "0000*"}, EPCs: []string{"3014AA*"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		sku  string
+		epc  string
+		gtin string
+		want Match
+	}{
+		{"included", "0123", "", "", Match{Lists: []string{"apparel"}, Included: true}},
+		{"included by epc", "9999", "3014AA01", "", Match{Lists: []string{"apparel"}, Included: true}},
+		{"excluded", "0000123", "", "", Match{Lists: []string{"store-use", "apparel"}, Excluded: "store-use", Included: true}},
+		{"watched", "0123", "", "80614141123458", Match{Lists: []string{"high-value", "apparel"}, Included: true}},
+		{"not included", "9999", "", "80614141123458", Match{Lists: []string{"high-value"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if match := set.Match(test.sku, test.epc, test.gtin); !reflect.DeepEqual(match, test.want) {
+				t.Errorf("expected %+v, but got %+v", test.want, match)
+			}
+		})
+	}
+
+	empty, _ := NewSet(nil)
+	if match := empty.Match("0123", "", ""); !match.Included || match.Excluded != "" {
+		t.Errorf("expected every tag to be included without any lists, but got %+v", match)
+	}
+}
+
+func writeFile(t *testing.T, filename string, content string) {
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadFilterLists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filterlists")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "lists.json")
+	writeFile(t, filename, `[
+		{"name": "store-use", "type": "exclude", "source": "store-use.csv"},
+		{"name": "high-value", "type": "watch", "skus": ["0123*"], "source": "high-value.json"}
+	]`)
+	writeFile(t, filepath.Join(dir, "store-use.csv"), "field,pattern\n# bags\nsku, 0000*\nepc,3014BB*\n")
+	writeFile(t, filepath.Join(dir, "high-value.json"), `{"gtins": ["80614141000000-80614141999999"]}`)
+
+	lists, err := config.LoadFilterLists(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 2 {
+		t.Fatalf("expected 2 lists, but got %d", len(lists))
+	}
+	if !reflect.DeepEqual(lists[0].SKUs, []string{"0000*"}) || !reflect.DeepEqual(lists[0].EPCs, []string{"3014BB*"}) {
+		t.Errorf("expected the patterns from store-use.csv, but got %+v", lists[0])
+	}
+	if !reflect.DeepEqual(lists[1].SKUs, []string{"0123*"}) || len(lists[1].GTINs) != 1 {
+		t.Errorf("expected the inline and source patterns of high-value, but got %+v", lists[1])
+	}
+
+	invalid := map[string]string{
+		"unknown type":   `[{"name": "a", "type": "allow"}]`,
+		"duplicate name": `[{"name": "a", "type": "watch"}, {"name": "a", "type": "watch"}]`,
+		"missing name":   `[{"type": "watch"}]`,
+		"invalid range":  `[{"name": "a", "type": "watch", "gtins": ["9-1"]}]`,
+		"missing source": `[{"name": "a", "type": "watch", "source": "missing.csv"}]`,
+	}
+	for name, content := range invalid {
+		writeFile(t, filename, content)
+		if _, err := config.LoadFilterLists(filename); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	writeFile(t, filepath.Join(dir, "bad.csv"), "color,red\n")
+	writeFile(t, filename, `[{"name": "a", "type": "watch", "source": "bad.csv"}]`)
+	if _, err := config.LoadFilterLists(filename); err == nil {
+		t.Error("expected an unknown csv field to fail")
+	}
+}
@@ -0,0 +1,93 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package fittingroom
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	visits = make(map[string]*Visit)
+	mutex  sync.Mutex
+)
+
+// Visit is an item that was taken into a fitting room and has not come back out to the sales floor
+type Visit struct {
+	EPC       string `json:"epc"`
+	ProductID string `json:"product_id"`
+	// Device id of the fitting room sensor
+	Sensor string `json:"sensor"`
+	// Antenna alias of the fitting room
+	Location string `json:"location"`
+	// Time the item was first read in the fitting room in milliseconds epoch
+	Entered int64 `json:"entered"`
+	// Whether an alert has already been raised for this visit
+	Alerted bool `json:"alerted"`
+}
+
+// Enter records an item being read in a fitting room. If the item is already in a fitting room,
+// the original visit is kept so the time spent keeps accumulating.
+func Enter(visit Visit) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, ok := visits[visit.EPC]; !ok {
+		visits[visit.EPC] = &visit
+	}
+}
+
+// Find returns an item's current fitting room visit, if there is one
+func Find(epc string) (Visit, bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	visit, ok := visits[epc]
+	if !ok {
+		return Visit{}, false
+	}
+	return *visit, true
+}
+
+// Remove forgets an item's fitting room visit, returning the visit if there was one. This is called
+// when an item comes back out to the sales floor, is sold, or leaves the store.
+func Remove(epc string) (Visit, bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	visit, ok := visits[epc]
+	if !ok {
+		return Visit{}, false
+	}
+	delete(visits, epc)
+	return *visit, true
+}
+
+// Overdue returns every item that has been in a fitting room for longer than timeout milliseconds
+// and has not been alerted on yet. The returned visits are marked as alerted. Alerted visits are
+// kept so that the item is still escalated if it exits, until they are older than maxAge milliseconds.
+func Overdue(now int64, timeout int64, maxAge int64) []Visit {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var overdue []Visit
+	for epc, visit := range visits {
+		if !visit.Alerted && now-visit.Entered > timeout {
+			visit.Alerted = true
+			overdue = append(overdue, *visit)
+		}
+		// items which are never read again would otherwise be kept forever
+		if visit.Alerted && now-visit.Entered > maxAge {
+			delete(visits, epc)
+		}
+	}
+
+	sort.Slice(overdue, func(i, j int) bool {
+		return overdue[i].Entered < overdue[j].Entered
+	})
+	return overdue
+}
@@ -0,0 +1,55 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package fittingroom
+
+import (
+	"testing"
+)
+
+func TestOverdue(t *testing.T) {
+	const timeout = 1000
+	const maxAge = 10000
+
+	Enter(Visit{EPC: "3014AA", Sensor: "RSP-1", Entered: 1000})
+	Enter(Visit{EPC: "3014BB", Sensor: "RSP-1", Entered: 1500})
+	// re-entering does not reset the time spent
+	Enter(Visit{EPC: "3014AA", Sensor: "RSP-1", Entered: 1900})
+
+	if overdue := Overdue(1900, timeout, maxAge); len(overdue) != 0 {
+		t.Errorf("Expected no overdue items, but got %+v", overdue)
+	}
+
+	overdue := Overdue(2100, timeout, maxAge)
+	if len(overdue) != 1 || overdue[0].EPC != "3014AA" {
+		t.Errorf("Expected 3014AA to be overdue, but got %+v", overdue)
+	}
+
+	// items are only alerted on once
+	if overdue := Overdue(2200, timeout, maxAge); len(overdue) != 0 {
+		t.Errorf("Expected no new overdue items, but got %+v", overdue)
+	}
+
+	if _, ok := Remove("3014BB"); !ok {
+		t.Error("Expected 3014BB to be in a fitting room")
+	}
+	if overdue := Overdue(5000, timeout, maxAge); len(overdue) != 0 {
+		t.Errorf("Expected no overdue items after removal, but got %+v", overdue)
+	}
+
+	visit, ok := Find("3014AA")
+	if !ok || !visit.Alerted {
+		t.Errorf("Expected alerted visit for 3014AA, but got %+v, %v", visit, ok)
+	}
+
+	// alerted visits are forgotten once they are older than the max age
+	if overdue := Overdue(11100, timeout, maxAge); len(overdue) != 0 {
+		t.Errorf("Expected no new overdue items, but got %+v", overdue)
+	}
+	if visit, ok := Find("3014AA"); ok {
+		t.Errorf("Expected the visit of 3014AA to be evicted, but got %+v", visit)
+	}
+}
@@ -0,0 +1,198 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package incident
+
+import "github.com/pborman/uuid"
+
+// Status is the review state of an incident
+type Status string
+
+const (
+	// StatusOpen incidents have not been looked at yet
+	StatusOpen Status = "open"
+	// StatusUnderReview incidents are being investigated by a reviewer
+	StatusUnderReview Status = "under_review"
+	// StatusFalsePositive incidents were reviewed and no loss occurred
+	StatusFalsePositive Status = "false_positive"
+	// StatusConfirmed incidents were reviewed and a loss did occur
+	StatusConfirmed Status = "confirmed"
+)
+
+// Type is the kind of event which raised an incident
+type Type string
+
+const (
+	// TypeExit incidents are raised when items leave through an exit, and are recorded on camera
+	TypeExit Type = "exit"
+	// TypeFittingRoom incidents are raised when items taken into a fitting room do not come back out
+	TypeFittingRoom Type = "fitting_room"
+)
+
+const (
+	// FlagRecentlySold is set on tags which were sold or passed a POS sensor shortly before exiting
+	FlagRecentlySold = "recently_sold"
+	// FlagFittingRoom is set on tags which exited after being taken into a fitting room without coming back out
+	FlagFittingRoom = "fitting_room"
+)
+
+const (
+	// these match the notification severities
+	severityCritical = "CRITICAL"
+	severityNormal   = "NORMAL"
+)
+
+// transitions lists the statuses each status is allowed to move to
+var transitions = map[Status][]Status{
+	StatusOpen:          {StatusUnderReview, StatusFalsePositive, StatusConfirmed},
+	StatusUnderReview:   {StatusOpen, StatusFalsePositive, StatusConfirmed},
+	StatusFalsePositive: {StatusUnderReview},
+	StatusConfirmed:     {StatusUnderReview},
+}
+
+// Incident is a single loss prevention event, covering every tag which triggered
+// the same recording on a camera
+type Incident struct {
+	ID   string `json:"id"`
+	Type Type   `json:"type"`
+	// Time the first tag triggered the incident in milliseconds epoch
+	Timestamp int64 `json:"timestamp"`
+	// Device id of the exit sensor the first tag was read at
+	Sensor string `json:"sensor"`
+	// Name of the camera which recorded the incident
+	Camera string `json:"camera"`
+	// Every tag which triggered this incident
+	Tags []Tag `json:"tags"`
+	// Recording folders (relative to the recordings folder) which belong to this incident
+	Recordings []string `json:"recordings"`
+	// Filenames of the object detections saved in the recording folder
+	Detections []string `json:"detections"`
+	// Risk assessment, only set when risk scoring is configured
+	Risk *Risk `json:"risk,omitempty"`
+	// Current review status
+	Status Status `json:"status"`
+	// History of every status change
+	Reviews []Review `json:"reviews"`
+	// Last time the incident was modified in milliseconds epoch
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// Tag is a single item which triggered an incident
+type Tag struct {
+	EPC       string `json:"epc"`
+	ProductID string `json:"product_id"`
+	// Name and price of the product from the catalog, if it is in the catalog
+	ProductName string  `json:"product_name,omitempty"`
+	Price       float64 `json:"price,omitempty"`
+	// GTIN-14 decoded from SGTIN EPCs, empty for any other EPC
+	GTIN string `json:"gtin,omitempty"`
+	// Device id of the sensor the tag was read at
+	Sensor string `json:"sensor"`
+	// Antenna alias the tag was read at
+	Location string `json:"location"`
+	// Time the tag triggered in milliseconds epoch
+	Timestamp int64 `json:"timestamp"`
+	// LowRisk tags are still recorded, but are less likely to be a loss (for example, recently sold)
+	LowRisk bool `json:"low_risk"`
+	// Reasons this tag was treated differently, such as FlagRecentlySold
+	Flags []string `json:"flags,omitempty"`
+	// Escalated tags are more likely to be a loss (for example, they never came back out of a fitting room)
+	Escalated bool `json:"escalated"`
+	// Number of repeated triggers of this tag which were suppressed by the cooldown
+	Repeats int `json:"repeats"`
+	// Names of the filter lists the tag matched, such as a high value watchlist
+	Lists []string `json:"lists,omitempty"`
+	// Name of the rule which triggered this tag
+	Rule string `json:"rule,omitempty"`
+	// Notification severity of the rule which triggered this tag
+	Severity string `json:"severity,omitempty"`
+}
+
+// Risk is the risk score of an incident, and what it decided
+type Risk struct {
+	Score    float64 `json:"score"`
+	Severity string  `json:"severity"`
+	// False if the score is too low to record the incident
+	Record bool `json:"record"`
+	// Recording duration in seconds, 0 for the default recording duration
+	RecordingDuration int `json:"recording_duration,omitempty"`
+	// How the score adds up, such as "3 item(s) x 5"
+	Factors []string `json:"factors"`
+}
+
+// Review is a single status change made by a reviewer
+type Review struct {
+	From     Status `json:"from"`
+	To       Status `json:"to"`
+	Reviewer string `json:"reviewer"`
+	Notes    string `json:"notes"`
+	// Time of the status change in milliseconds epoch
+	Timestamp int64 `json:"timestamp"`
+}
+
+// NewIncident returns a new open exit Incident for the given camera
+func NewIncident(timestamp int64, camera string) *Incident {
+	return &Incident{
+		ID:        uuid.New(),
+		Type:      TypeExit,
+		Timestamp: timestamp,
+		Camera:    camera,
+		Status:    StatusOpen,
+		UpdatedAt: timestamp,
+	}
+}
+
+// AddTags attaches more triggering tags to the incident, ignoring any EPCs it already contains
+func (incident *Incident) AddTags(tags ...Tag) {
+	for _, tag := range tags {
+		if incident.Sensor == "" {
+			incident.Sensor = tag.Sensor
+		}
+		if !incident.HasEPC(tag.EPC) {
+			incident.Tags = append(incident.Tags, tag)
+		}
+	}
+}
+
+// HasEPC returns true if the incident already contains a tag with the given EPC
+func (incident *Incident) HasEPC(epc string) bool {
+	for _, tag := range incident.Tags {
+		if tag.EPC == epc {
+			return true
+		}
+	}
+	return false
+}
+
+// Severity returns the notification severity of the incident. If the incident has a risk assessment,
+// that decides the severity. Otherwise it is CRITICAL if any of its tags are CRITICAL (or do not have a severity).
+func (incident *Incident) Severity() string {
+	if incident.Risk != nil {
+		return incident.Risk.Severity
+	}
+	for _, tag := range incident.Tags {
+		if tag.Severity == "" || tag.Severity == severityCritical {
+			return severityCritical
+		}
+	}
+	return severityNormal
+}
+
+// IsValid returns true if status is a known status
+func (status Status) IsValid() bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// CanTransitionTo returns true if an incident is allowed to move from status to next
+func (status Status) CanTransitionTo(next Status) bool {
+	for _, allowed := range transitions[status] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
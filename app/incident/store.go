@@ -0,0 +1,180 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package incident
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	fileMode = 0777
+)
+
+var (
+	// ErrNotFound is returned when an incident does not exist
+	ErrNotFound = errors.New("incident not found")
+	// ErrInvalidStatus is returned when an unknown status is requested
+	ErrInvalidStatus = errors.New("invalid incident status")
+	// ErrInvalidTransition is returned when an incident is not allowed to move to the requested status
+	ErrInvalidTransition = errors.New("invalid incident status transition")
+	// ErrStoreNotOpen is returned when the store is used before calling Open
+	ErrStoreNotOpen = errors.New("incident store is not open")
+
+	incidentsBucket = []byte("incidents")
+
+	db *bbolt.DB
+)
+
+// Open opens (or creates) the single file incident database
+func Open(filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), fileMode); err != nil {
+		return errors.Wrapf(err, "unable to create incident database folder for %s", filename)
+	}
+
+	var err error
+	if db, err = bbolt.Open(filename, 0600, &bbolt.Options{Timeout: 5 * time.Second}); err != nil {
+		return errors.Wrapf(err, "unable to open incident database %s", filename)
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(incidentsBucket)
+		return err
+	})
+}
+
+// Close closes the incident database
+func Close() error {
+	if db == nil {
+		return nil
+	}
+	err := db.Close()
+	db = nil
+	return err
+}
+
+// Save inserts or replaces an incident
+func Save(incident *Incident) error {
+	if db == nil {
+		return ErrStoreNotOpen
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		return put(tx, incident)
+	})
+}
+
+// Find returns the incident with the given id
+func Find(id string) (*Incident, error) {
+	if db == nil {
+		return nil, ErrStoreNotOpen
+	}
+
+	var incident *Incident
+	err := db.View(func(tx *bbolt.Tx) error {
+		var err error
+		incident, err = get(tx, id)
+		return err
+	})
+	return incident, err
+}
+
+// List returns every incident, optionally only those with the given status, newest first
+func List(status Status) ([]Incident, error) {
+	if db == nil {
+		return nil, ErrStoreNotOpen
+	}
+
+	incidents := make([]Incident, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(incidentsBucket).ForEach(func(k, v []byte) error {
+			var incident Incident
+			if err := json.Unmarshal(v, &incident); err != nil {
+				return errors.Wrapf(err, "unable to decode incident %s", k)
+			}
+			if status == "" || incident.Status == status {
+				incidents = append(incidents, incident)
+			}
+			return nil
+		})
+	})
+
+	sort.Slice(incidents, func(i, j int) bool {
+		return incidents[i].Timestamp > incidents[j].Timestamp
+	})
+	return incidents, err
+}
+
+// Update atomically modifies an existing incident
+func Update(id string, timestamp int64, modify func(incident *Incident) error) (*Incident, error) {
+	if db == nil {
+		return nil, ErrStoreNotOpen
+	}
+
+	var incident *Incident
+	err := db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if incident, err = get(tx, id); err != nil {
+			return err
+		}
+		if err = modify(incident); err != nil {
+			return err
+		}
+		incident.UpdatedAt = timestamp
+		return put(tx, incident)
+	})
+	return incident, err
+}
+
+// Transition moves an incident to a new review status, recording who did it and why
+func Transition(id string, status Status, reviewer string, notes string, timestamp int64) (*Incident, error) {
+	if !status.IsValid() {
+		return nil, errors.Wrapf(ErrInvalidStatus, "%s", status)
+	}
+
+	return Update(id, timestamp, func(incident *Incident) error {
+		if !incident.Status.CanTransitionTo(status) {
+			return errors.Wrapf(ErrInvalidTransition, "%s -> %s", incident.Status, status)
+		}
+
+		incident.Reviews = append(incident.Reviews, Review{
+			From:      incident.Status,
+			To:        status,
+			Reviewer:  reviewer,
+			Notes:     notes,
+			Timestamp: timestamp,
+		})
+		incident.Status = status
+		return nil
+	})
+}
+
+func get(tx *bbolt.Tx, id string) (*Incident, error) {
+	data := tx.Bucket(incidentsBucket).Get([]byte(id))
+	if data == nil {
+		return nil, errors.Wrapf(ErrNotFound, "%s", id)
+	}
+
+	incident := new(Incident)
+	if err := json.Unmarshal(data, incident); err != nil {
+		return nil, errors.Wrapf(err, "unable to decode incident %s", id)
+	}
+	return incident, nil
+}
+
+func put(tx *bbolt.Tx, incident *Incident) error {
+	data, err := json.Marshal(incident)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(incidentsBucket).Put([]byte(incident.ID), data)
+}
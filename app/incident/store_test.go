@@ -0,0 +1,128 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package incident
+
+import (
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestStore(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "incidents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Open(filepath.Join(dir, "incidents.db")); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		if err := Close(); err != nil {
+			t.Error(err)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSaveAndFind(t *testing.T) {
+	defer openTestStore(t)()
+
+	inc := NewIncident(1000, "front-door")
+	inc.AddTags(Tag{EPC: "3014", ProductID: "111", Sensor: "RSP-150000"}, Tag{EPC: "3015", ProductID: "222"}, Tag{EPC: "3014"})
+	if err := Save(inc); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := Find(inc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Tags) != 2 {
+		t.Errorf("Expected 2 tags, but got %d", len(found.Tags))
+	}
+	if found.Sensor != "RSP-150000" {
+		t.Errorf("Expected sensor RSP-150000, but got %s", found.Sensor)
+	}
+	if found.Status != StatusOpen {
+		t.Errorf("Expected status %s, but got %s", StatusOpen, found.Status)
+	}
+
+	if _, err := Find("missing"); errors.Cause(err) != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, but got %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	defer openTestStore(t)()
+
+	for _, ts := range []int64{1000, 3000, 2000} {
+		if err := Save(NewIncident(ts, "front-door")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	incidents, err := List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incidents) != 3 {
+		t.Fatalf("Expected 3 incidents, but got %d", len(incidents))
+	}
+	if incidents[0].Timestamp != 3000 || incidents[2].Timestamp != 1000 {
+		t.Errorf("Expected incidents to be sorted newest first, but got %+v", incidents)
+	}
+
+	if incidents, err = List(StatusConfirmed); err != nil || len(incidents) != 0 {
+		t.Errorf("Expected no confirmed incidents, but got %d, %v", len(incidents), err)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	defer openTestStore(t)()
+
+	inc := NewIncident(1000, "front-door")
+	if err := Save(inc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		status   Status
+		expected error
+	}{
+		{status: StatusUnderReview},
+		{status: "bogus", expected: ErrInvalidStatus},
+		{status: StatusConfirmed},
+		{status: StatusFalsePositive, expected: ErrInvalidTransition},
+		{status: StatusUnderReview},
+		{status: StatusFalsePositive},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.status), func(t *testing.T) {
+			_, err := Transition(inc.ID, test.status, "jane", "notes", 2000)
+			if errors.Cause(err) != test.expected {
+				t.Errorf("Expected error %v, but got %v", test.expected, err)
+			}
+		})
+	}
+
+	found, err := Find(inc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Status != StatusFalsePositive {
+		t.Errorf("Expected status %s, but got %s", StatusFalsePositive, found.Status)
+	}
+	if len(found.Reviews) != 4 {
+		t.Errorf("Expected 4 reviews, but got %d", len(found.Reviews))
+	}
+	if found.UpdatedAt != 2000 {
+		t.Errorf("Expected updated_at of 2000, but got %d", found.UpdatedAt)
+	}
+}
@@ -0,0 +1,226 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package lossprevention
+
+import (
+	"fmt"
+	"github.com/edgexfoundry/app-functions-sdk-go/appcontext"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/fittingroom"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/incident"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/notification"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/clock"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/sensor"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// how often fitting rooms are checked for overdue items, in between inventory events
+	fittingRoomCheckInterval = 10 * time.Second
+)
+
+var (
+	// the most recent EdgeX context, used to send fitting room alerts from the ticker
+	fittingRoomContext      *appcontext.Context
+	fittingRoomContextMutex sync.Mutex
+)
+
+// trackFittingRoom follows items into and out of fitting rooms. If the tag is now at an exit
+// after being taken into a fitting room, the fitting room visit is returned.
+func trackFittingRoom(tag *Tag, now int64) (fittingroom.Visit, bool) {
+	if !config.AppConfig.EnableFittingRoomAlerts || len(tag.LocationHistory) == 0 {
+		return fittingroom.Visit{}, false
+	}
+
+	location := tag.LocationHistory[0]
+	rsp := sensor.FindByAntennaAlias(location.Location)
+	if rsp == nil {
+		return fittingroom.Visit{}, false
+	}
+
+	switch {
+	case rsp.IsFittingRoomSensor():
+		entered := location.Timestamp
+		if entered == 0 {
+			entered = now
+		}
+		fittingroom.Enter(fittingroom.Visit{
+			EPC:       tag.Epc,
+			ProductID: tag.ProductID,
+			Sensor:    rsp.DeviceId,
+			Location:  location.Location,
+			Entered:   entered,
+		})
+		return fittingroom.Visit{}, false
+
+	case rsp.IsExitSensor():
+		visit, ok := fittingroom.Remove(tag.Epc)
+		if ok {
+			logrus.Debugf("tag exiting after being taken into fitting room %s: epc: %s (sku: %s)", visit.Location, tag.Epc, tag.ProductID)
+		}
+		return visit, ok
+
+	default:
+		// back out on the sales floor (or at the POS)
+		if _, ok := fittingroom.Remove(tag.Epc); ok {
+			logrus.Debugf("tag returned from fitting room: epc: %s (sku: %s)", tag.Epc, tag.ProductID)
+		}
+		return fittingroom.Visit{}, false
+	}
+}
+
+// peekFittingRoom returns the fitting room visit an exiting tag would be escalated for, without
+// changing any visits
+func peekFittingRoom(tag *Tag) (fittingroom.Visit, bool) {
+	if !config.AppConfig.EnableFittingRoomAlerts || len(tag.LocationHistory) == 0 {
+		return fittingroom.Visit{}, false
+	}
+
+	rsp := sensor.FindByAntennaAlias(tag.LocationHistory[0].Location)
+	if rsp == nil || !rsp.IsExitSensor() {
+		return fittingroom.Visit{}, false
+	}
+	return fittingroom.Find(tag.Epc)
+}
+
+// WatchFittingRooms checks for overdue fitting room items every few seconds on the clock, so that alerts are
+// raised on time even when no inventory events are received. It returns right away, along with a function
+// which stops watching.
+func WatchFittingRooms() (stop func()) {
+	if !config.AppConfig.EnableFittingRoomAlerts {
+		return func() {}
+	}
+
+	var mutex sync.Mutex
+	stopped := false
+	var timer clock.Timer
+	var tick func()
+	tick = func() {
+		checkOverdueFittingRooms()
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !stopped {
+			timer = clock.AfterFunc(fittingRoomCheckInterval, tick)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	timer = clock.AfterFunc(fittingRoomCheckInterval, tick)
+
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		stopped = true
+		timer.Stop()
+	}
+}
+
+// checkOverdueFittingRooms checks the fitting rooms in between inventory events
+func checkOverdueFittingRooms() {
+	fittingRoomContextMutex.Lock()
+	edgexcontext := fittingRoomContext
+	fittingRoomContextMutex.Unlock()
+
+	// until an inventory event is received, no item can be in a fitting room
+	if edgexcontext == nil {
+		return
+	}
+	evaluationMutex.Lock()
+	checkFittingRooms(edgexcontext, clock.Now())
+	evaluationMutex.Unlock()
+}
+
+func setFittingRoomContext(edgexcontext *appcontext.Context) {
+	fittingRoomContextMutex.Lock()
+	defer fittingRoomContextMutex.Unlock()
+
+	if edgexcontext != nil {
+		fittingRoomContext = edgexcontext
+	}
+}
+
+// checkFittingRooms raises an alert for every item that has been in a fitting room for longer than
+// the fitting room timeout. Items from the same fitting room are grouped into a single incident.
+// Note: this is evaluated whenever an inventory event is received, and by WatchFittingRooms.
+func checkFittingRooms(edgexcontext *appcontext.Context, now int64) {
+	if !config.AppConfig.EnableFittingRoomAlerts {
+		return
+	}
+
+	overdue := fittingroom.Overdue(now, int64(config.AppConfig.FittingRoomTimeout)*1000,
+		int64(config.AppConfig.FittingRoomMaxVisitAge)*1000)
+	if len(overdue) == 0 {
+		return
+	}
+
+	var sensors []string
+	incidents := make(map[string]*incident.Incident)
+	for _, visit := range overdue {
+		inc, ok := incidents[visit.Sensor]
+		if !ok {
+			inc = incident.NewIncident(now, "")
+			inc.Type = incident.TypeFittingRoom
+			incidents[visit.Sensor] = inc
+			sensors = append(sensors, visit.Sensor)
+		}
+		inc.AddTags(incident.Tag{
+			EPC:       visit.EPC,
+			ProductID: visit.ProductID,
+			Sensor:    visit.Sensor,
+			Location:  visit.Location,
+			Timestamp: visit.Entered,
+		})
+	}
+
+	for _, sensorId := range sensors {
+		inc := incidents[sensorId]
+		logrus.Infof("%d item(s) have not returned from fitting room %s", len(inc.Tags), sensorId)
+		if config.AppConfig.DryRun {
+			logrus.Infof("dry run: would notify on fitting room %s", sensorId)
+			reportDryRun(inc)
+			continue
+		}
+		if err := incident.Save(inc); err != nil {
+			logrus.Errorf("unable to save incident %s: %v", inc.ID, err)
+		}
+		notifyFittingRoomIncident(edgexcontext, inc)
+	}
+}
+
+func notifyFittingRoomIncident(edgexcontext *appcontext.Context, inc *incident.Incident) {
+	if incidentHandler != nil {
+		incidentHandler(inc)
+		return
+	}
+
+	format := `
+%d item(s) taken into a fitting room have not returned to the sales floor after %v.
+
+  Incident: %s
+ Timestamp: %d
+    Sensor: %s
+%s
+`
+	var items strings.Builder
+	for _, tag := range inc.Tags {
+		fmt.Fprintf(&items, `
+Product ID: %s
+       EPC: %s
+  Location: %s
+`, tag.ProductID, tag.EPC, tag.Location)
+	}
+	content := fmt.Sprintf(format, len(inc.Tags), time.Duration(config.AppConfig.FittingRoomTimeout)*time.Second, inc.ID, inc.Timestamp, inc.Sensor, items.String())
+
+	if err := notification.PostNotification(edgexcontext, notification.SeverityNormal, content); err != nil {
+		logrus.Error(err)
+	}
+}
@@ -10,84 +10,610 @@ import (
 	"fmt"
 	"github.com/edgexfoundry/app-functions-sdk-go/appcontext"
 	"github.com/sirupsen/logrus"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/catalog"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/cooldown"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/decision"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/filterlist"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/fittingroom"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/incident"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/notification"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/pos"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/risk"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/rules"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/camera"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/clock"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/direction"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/expression"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/jsonrpc"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/sensor"
-	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/sgtin"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
 	moved              = "moved"
-	videoFolderPattern = "/recordings/%v_%s_%s"
+	videoFolderPattern = "%v_%s_%s_%s"
+)
+
+var (
+	// incidentHandler receives every incident instead of it being notified, including those dry run mode
+	// would have generated
+	incidentHandler func(inc *incident.Incident)
+
+	// events are evaluated by the EdgeX pipeline, the segment timeout and the fitting room ticker, each on its
+	// own goroutine. Evaluation is serialized so that the cooldown, fitting room and camera queue decisions
+	// of a tag cannot interleave with those of another event, which would let the same EPC trigger twice.
+	evaluationMutex sync.Mutex
 )
 
 func HandleDataPayload(edgexcontext *appcontext.Context, payload *DataPayload) error {
+	evaluationMutex.Lock()
+	defer evaluationMutex.Unlock()
+
+	timestamp := clock.Now()
+
+	// cooldown in milliseconds of every triggering tag, and the incidents it was added to
+	cooldowns := make(map[string]int64)
+	incidents := make(map[string][]string)
+
+	saleWindow := int64(config.AppConfig.SaleReconciliationWindow) * 1000
+
+	setFittingRoomContext(edgexcontext)
+	checkFittingRooms(edgexcontext, timestamp)
+
+	tags, invalid := payload.validTags(timestamp)
+	for _, dropped := range invalid {
+		reason := jsonrpc.FailureReason(dropped.err)
+		metrics.GetOrRegisterCounter("loss-prevention-service.DataPayload.InvalidTag."+reason, nil).Inc(1)
+		logrus.WithFields(logrus.Fields{
+			"Method": "HandleDataPayload",
+			"Reason": reason,
+			"Error":  dropped.err.Error(),
+		}).Warn("dropping invalid tag")
+		decision.Record(dropped.decision)
+	}
+
+	results := make([]evaluation, 0, len(tags))
+	for i := range tags {
+		result := evaluateTag(&tags[i], timestamp, saleWindow, cooldowns, false)
+		if result.decision.Triggered {
+			cooldowns[result.tag.EPC] = result.cooldown
+		}
+		results = append(results, result)
+	}
+
+	// decisions are only recorded once the risk score has decided which tags are recorded
+	plan := planIncidents(results, timestamp)
+	for _, result := range results {
+		decision.Record(result.decision)
+	}
+	notified, cameras, triggered := plan.notified, plan.cameras, plan.triggered
+
+	if config.AppConfig.DryRun {
+		dryRun(timestamp, notified, cameras, triggered)
+	} else {
+		if len(notified) > 0 {
+			id := notifyWithoutRecording(edgexcontext, timestamp, notified)
+			for _, tag := range notified {
+				incidents[tag.EPC] = append(incidents[tag.EPC], id)
+			}
+		}
+
+		for _, cam := range cameras {
+			id := getOrStartCameraQueue(cam).trigger(edgexcontext, timestamp, triggered[cam])
+			for _, tag := range triggered[cam] {
+				incidents[tag.EPC] = append(incidents[tag.EPC], id)
+			}
+		}
+	}
 
-	for _, tag := range payload.TagEvent {
-		if tag.Event != moved {
-			logrus.Debugf("skipping non-moved event: epc: %s (sku: %s), event: %s", tag.Epc, tag.ProductID, tag.Event)
+	for epc, duration := range cooldowns {
+		if duration > 0 {
+			cooldown.Start(epc, incidents[epc], timestamp, duration)
+		}
+	}
+
+	return nil
+}
+
+// evaluation is the outcome of evaluating a single tag
+type evaluation struct {
+	decision decision.Decision
+	// the tag to add to an incident, if it triggered
+	tag incident.Tag
+	// cameras to record the tag on, if its rule records
+	cameras []*camera.Camera
+	// cooldown of the tag in milliseconds
+	cooldown int64
+}
+
+// evaluateTag runs a single tag through the trigger logic. seen holds every EPC which already triggered
+// in the same payload. When explain is true, the evaluation has no side effects: POS reads, fitting room
+// visits, cooldowns and the shadow rule comparison are only looked at, never changed.
+func evaluateTag(tag *Tag, timestamp int64, saleWindow int64, seen map[string]int64, explain bool) evaluation {
+	result := evaluation{decision: newDecision(tag, timestamp)}
+	d := &result.decision
+
+	var visit fittingroom.Visit
+	var fromFittingRoom bool
+	if explain {
+		visit, fromFittingRoom = peekFittingRoom(tag)
+	} else {
+		recordPOSRead(tag, timestamp, saleWindow)
+		visit, fromFittingRoom = trackFittingRoom(tag, timestamp)
+	}
+
+	if len(tag.LocationHistory) == 0 {
+		d.Reject(decision.StageLocationHistory, "tag has no location history")
+		return result
+	}
+
+	ctx := newRuleContext(tag, timestamp)
+	if ctx.SGTIN != nil {
+		d.GTIN = ctx.SGTIN.GTIN
+	}
+	if ctx.To != nil {
+		d.Sensor, d.SensorPersonality = ctx.To.DeviceId, string(ctx.To.Personality)
+	}
+	if ctx.From != nil {
+		d.PreviousSensor, d.PreviousPersonality = ctx.From.DeviceId, string(ctx.From.Personality)
+	}
+	d.Direction = ctx.Direction
+
+	if !config.AppConfig.SKUFilterRegex.MatchString(tag.ProductID) {
+		d.Reject(decision.StageSKUFilter, "sku does not match filter %s", config.AppConfig.SKUFilter)
+		return result
+	}
+	if !config.AppConfig.EPCFilterRegex.MatchString(tag.Epc) {
+		d.Reject(decision.StageEPCFilter, "epc does not match filter %s", config.AppConfig.EPCFilter)
+		return result
+	}
+	// tags which are not SGTINs have no GTIN, and only pass the default filter
+	if !config.AppConfig.GTINFilterRegex.MatchString(d.GTIN) {
+		d.Reject(decision.StageGTINFilter, "gtin %q does not match filter %s", d.GTIN, config.AppConfig.GTINFilter)
+		return result
+	}
+
+	match := filterlist.Active().Match(tag.ProductID, tag.Epc, d.GTIN)
+	d.Lists, ctx.Lists = match.Lists, match.Lists
+	if match.Excluded != "" {
+		d.Reject(decision.StageFilterList, "excluded by filter list %s", match.Excluded)
+		return result
+	}
+	if !match.Included {
+		d.Reject(decision.StageFilterList, "not in any include filter list")
+		return result
+	}
+
+	rule, ok := rules.Active().Evaluate(ctx)
+	if !explain {
+		rules.Compare(ctx, rules.VerdictOf(rule, ok), timestamp)
+	}
+	if !ok {
+		d.Reject(decision.StageRules, "%s event does not match any rule", tag.Event)
+		return result
+	}
+	action := rule.Action()
+	d.Rule, d.Action = rule.Name(), action.Type
+	if action.Type == config.RuleActionIgnore {
+		d.Reject(decision.StageRules, "ignored by rule %s", rule.Name())
+		return result
+	}
+
+	if explain {
+		if entry, ok := cooldown.Active(tag.Epc, timestamp); ok {
+			d.Reject(decision.StageCooldown, "repeated trigger in cooldown, repeats: %d, incidents: %v", entry.Repeats, entry.Incidents)
+			return result
+		}
+	} else if entry, ok := cooldown.Suppress(tag.Epc, timestamp); ok {
+		d.Reject(decision.StageCooldown, "repeated trigger in cooldown, repeats: %d, incidents: %v", entry.Repeats, entry.Incidents)
+		countRepeat(entry, timestamp)
+		return result
+	}
+	if _, ok := seen[tag.Epc]; ok {
+		d.Reject(decision.StageCooldown, "duplicate tag in the same payload")
+		return result
+	}
+
+	var reasons []string
+	lowRisk := false
+	var flags []string
+	if sale, ok := pos.Lookup(tag.Epc, timestamp, saleWindow); ok {
+		if config.AppConfig.SoldItemAction == config.SoldItemActionSuppress {
+			d.Reject(decision.StageSold, "recently sold, source: %s", sale.Source)
+			return result
+		}
+		reasons = append(reasons, fmt.Sprintf("flagged as low risk, recently sold, source: %s", sale.Source))
+		lowRisk = true
+		flags = append(flags, incident.FlagRecentlySold)
+
+		// a sold item may leave the store after being tried on, so it is not escalated
+		if fromFittingRoom {
+			fromFittingRoom = false
+			if !explain {
+				fittingroom.Remove(tag.Epc)
+			}
+		}
+	}
+	if fromFittingRoom {
+		reasons = append(reasons, fmt.Sprintf("escalated, never came back out of fitting room %s", visit.Location))
+		flags = append(flags, incident.FlagFittingRoom)
+	}
+
+	result.tag = incident.Tag{
+		EPC:       tag.Epc,
+		ProductID: tag.ProductID,
+		GTIN:      d.GTIN,
+		Lists:     d.Lists,
+		Sensor:    d.Sensor,
+		Location:  d.Location,
+		Timestamp: timestamp,
+		LowRisk:   lowRisk,
+		Escalated: fromFittingRoom,
+		Flags:     flags,
+		Rule:      rule.Name(),
+		Severity:  action.Severity,
+	}
+	if product, ok := catalog.Lookup(tag.ProductID); ok {
+		result.tag.ProductName, result.tag.Price = product.Name, product.Price
+	}
+	result.cooldown = int64(config.AppConfig.CooldownOverrides.Cooldown(tag.ProductID, d.Sensor, config.AppConfig.TriggerCooldown)) * 1000
+
+	if action.Type == config.RuleActionRecord {
+		result.cameras = camera.FindCoveringCameras(d.Sensor, d.Location)
+		if len(result.cameras) == 0 {
+			d.Reject(decision.StageCamera, "no camera covers sensor %s (alias: %s)", d.Sensor, d.Location)
+			logrus.Warnf("no camera covers sensor %s (alias: %s), unable to record tag matching rule %s: epc: %s (sku: %s)", d.Sensor, d.Location, rule.Name(), tag.Epc, tag.ProductID)
+			return result
+		}
+		for _, cam := range result.cameras {
+			d.Cameras = append(d.Cameras, cam.Name)
+		}
+	}
+
+	d.Accept("%s by rule %s%s", action.Type, rule.Name(), joinReasons(reasons))
+	return result
+}
+
+// Explain runs every tag of the payload through the trigger logic, against the current sensor registry
+// and configuration, and returns the decision made for each tag. Nothing is recorded or notified, and
+// nothing is remembered: the decision log, cooldowns, POS reads and fitting room visits are left untouched.
+func Explain(payload *DataPayload) []decision.Decision {
+	timestamp := clock.Now()
+	saleWindow := int64(config.AppConfig.SaleReconciliationWindow) * 1000
+	seen := make(map[string]int64)
+
+	tags, invalid := payload.validTags(timestamp)
+	decisions := make([]decision.Decision, 0, len(payload.TagEvent))
+	for _, dropped := range invalid {
+		decisions = append(decisions, dropped.decision)
+	}
+	results := make([]evaluation, 0, len(tags))
+	for i := range tags {
+		result := evaluateTag(&tags[i], timestamp, saleWindow, seen, true)
+		if result.decision.Triggered {
+			seen[result.tag.EPC] = result.cooldown
+		}
+		results = append(results, result)
+	}
+
+	planIncidents(results, timestamp)
+	for _, result := range results {
+		decisions = append(decisions, result.decision)
+	}
+	return decisions
+}
+
+// incidentPlan is how the tags which triggered in a payload are grouped into incidents
+type incidentPlan struct {
+	// tags which only notify, grouped into a single incident without a recording
+	notified []incident.Tag
+	// cameras to record on, in the order they were first triggered, each with its own incident
+	cameras   []*camera.Camera
+	triggered map[*camera.Camera][]incident.Tag
+}
+
+// planIncidents groups the triggered tags into a single incident per camera, and a single incident of tags which
+// only notify. Tags on a camera whose incident scores too low to record are only notified, unless they are recorded
+// on another camera, so that no tag is both recorded and notified on its own. The decision of every triggered tag
+// is updated with how it ends up being handled, and the risk score of its incident.
+func planIncidents(results []evaluation, timestamp int64) incidentPlan {
+	plan := incidentPlan{triggered: make(map[*camera.Camera][]incident.Tag)}
+
+	var candidates []*camera.Camera
+	for _, result := range results {
+		if !result.decision.Triggered {
 			continue
 		}
-		if len(tag.LocationHistory) < 2 {
-			logrus.Debugf("skipping tag with not enough location history: epc: %s (sku: %s)", tag.Epc, tag.ProductID)
+		for _, cam := range result.cameras {
+			if _, ok := plan.triggered[cam]; !ok {
+				candidates = append(candidates, cam)
+			}
+			plan.triggered[cam] = append(plan.triggered[cam], result.tag)
+		}
+	}
+
+	recordedOn := make(map[string][]string)
+	assessments := make(map[string]*incident.Risk)
+	for _, cam := range candidates {
+		tags := plan.triggered[cam]
+		assessment := risk.Assess(tags, timestamp)
+		if assessment != nil && !assessment.Record {
+			logrus.Debugf("risk score %v is below the record score, not recording %d tag(s) on camera %s", assessment.Score, len(tags), cam.Name)
+			delete(plan.triggered, cam)
+			for _, tag := range tags {
+				if _, ok := assessments[tag.EPC]; !ok {
+					assessments[tag.EPC] = assessment
+				}
+			}
 			continue
 		}
+		plan.cameras = append(plan.cameras, cam)
+		for _, tag := range tags {
+			recordedOn[tag.EPC] = append(recordedOn[tag.EPC], cam.Name)
+			assessments[tag.EPC] = assessment
+		}
+	}
 
-		if !config.AppConfig.SKUFilterRegex.MatchString(tag.ProductID) {
-			logrus.Debugf("skipping tag that does not match sku filter: epc: %s (sku: %s), filter: %s", tag.Epc, tag.ProductID, config.AppConfig.SKUFilter)
+	var notifiedResults []*evaluation
+	for i := range results {
+		result := &results[i]
+		if !result.decision.Triggered {
 			continue
 		}
-		if !config.AppConfig.EPCFilterRegex.MatchString(tag.Epc) {
-			logrus.Debugf("skipping tag that does not match epc filter: epc: %s (sku: %s), filter: %s", tag.Epc, tag.ProductID, config.AppConfig.EPCFilter)
+		d := &result.decision
+		if cameras, ok := recordedOn[result.tag.EPC]; ok {
+			d.Cameras = cameras
+			d.Risk = assessments[result.tag.EPC]
 			continue
 		}
+		if d.Action == config.RuleActionRecord {
+			d.Action, d.Cameras = config.RuleActionNotify, nil
+			d.Reason += fmt.Sprintf(", notified without recording, risk score %v is below the record score", assessments[result.tag.EPC].Score)
+		}
+		plan.notified = append(plan.notified, result.tag)
+		notifiedResults = append(notifiedResults, result)
+	}
 
-		rsp := sensor.FindByAntennaAlias(tag.LocationHistory[0].Location)
-		logrus.Debugf("current: %+v", rsp)
-		if rsp == nil || !rsp.IsExitSensor() {
-			logrus.Debugf("skipping non-exiting tag: epc: %s (sku: %s)", tag.Epc, tag.ProductID)
-			continue
+	if len(plan.notified) > 0 {
+		assessment := risk.Assess(plan.notified, timestamp)
+		for _, result := range notifiedResults {
+			result.decision.Risk = assessment
 		}
+	}
+	return plan
+}
 
-		rsp2 := sensor.FindByAntennaAlias(tag.LocationHistory[1].Location)
-		logrus.Debugf("previous: %+v", rsp2)
-		if rsp2 == nil || rsp2.IsExitSensor() {
-			logrus.Debugf("skipping exiting tag that was exiting before as well: epc: %s (sku: %s)", tag.Epc, tag.ProductID)
-			continue
+// newDecision starts the decision of a tag, before anything about it has been decided
+func newDecision(tag *Tag, timestamp int64) decision.Decision {
+	d := decision.Decision{
+		EPC:       tag.Epc,
+		ProductID: tag.ProductID,
+		Event:     tag.Event,
+		Timestamp: timestamp,
+		DryRun:    config.AppConfig.DryRun,
+	}
+	if len(tag.LocationHistory) > 0 {
+		d.Location = tag.LocationHistory[0].Location
+	}
+	return d
+}
+
+func joinReasons(reasons []string) string {
+	if len(reasons) == 0 {
+		return ""
+	}
+	return ", " + strings.Join(reasons, ", ")
+}
+
+// OnIncident sets a function which receives every incident instead of subscribers being notified of it, such as
+// when replaying captured events. It also receives every incident dry run mode would have generated. It must be
+// set before any events are handled, and nil goes back to notifying subscribers.
+func OnIncident(handler func(inc *incident.Incident)) {
+	incidentHandler = handler
+}
+
+func reportDryRun(inc *incident.Incident) {
+	if incidentHandler != nil {
+		incidentHandler(inc)
+	}
+}
+
+// dryRun logs what would have been notified and recorded, without touching the cameras or sending notifications
+func dryRun(timestamp int64, notified []incident.Tag, cameras []*camera.Camera, triggered map[*camera.Camera][]incident.Tag) {
+	if len(notified) > 0 {
+		logrus.Infof("dry run: would notify on %d tag(s) without recording: %s", len(notified), describeTags(notified))
+		inc := incident.NewIncident(timestamp, "")
+		inc.AddTags(notified...)
+		inc.Risk = risk.Assess(inc.Tags, timestamp)
+		reportDryRun(inc)
+	}
+	for _, cam := range cameras {
+		logrus.Infof("dry run: would record %d tag(s) on camera %s: %s", len(triggered[cam]), cam.Name, describeTags(triggered[cam]))
+		inc := incident.NewIncident(timestamp, cam.Name)
+		addTags(inc, triggered[cam])
+		reportDryRun(inc)
+	}
+}
+
+func describeTags(tags []incident.Tag) string {
+	descriptions := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		descriptions = append(descriptions, fmt.Sprintf("epc: %s (sku: %s, rule: %s)", tag.EPC, tag.ProductID, tag.Rule))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// countRepeat attaches a suppressed repeat trigger to every incident the EPC originally triggered
+func countRepeat(entry cooldown.Entry, now int64) {
+	for _, id := range entry.Incidents {
+		if _, err := incident.Update(id, now, func(inc *incident.Incident) error {
+			for i := range inc.Tags {
+				if inc.Tags[i].EPC == entry.EPC {
+					inc.Tags[i].Repeats = entry.Repeats
+				}
+			}
+			return nil
+		}); err != nil {
+			logrus.Errorf("unable to count repeated trigger of %s on incident %s: %v", entry.EPC, id, err)
 		}
+	}
+}
 
-		logrus.Debugf("triggering on exiting tag: epc: %s (sku: %s)", tag.Epc, tag.ProductID)
-		go triggerRecord(edgexcontext, &tag)
-		// return so we do not keep checking
-		return nil
+// newRuleContext resolves the sensors a tag moved between, so that rules can be evaluated against it
+func newRuleContext(tag *Tag, now int64) *rules.Context {
+	ctx := &rules.Context{
+		EPC:             tag.Epc,
+		ProductID:       tag.ProductID,
+		Event:           tag.Event,
+		FacilityID:      tag.FacilityID,
+		Confidence:      tag.Confidence,
+		Time:            time.Unix(0, now*int64(time.Millisecond)),
+		Tag:             tag,
+		LocationHistory: tag.LocationHistory,
 	}
+	if decoded, err := sgtin.Decode(tag.Epc); err == nil {
+		ctx.SGTIN = decoded
+	}
+	steps := make([]direction.Step, 0, len(tag.LocationHistory))
+	for _, history := range tag.LocationHistory {
+		steps = append(steps, direction.Step{
+			Location:  history.Location,
+			Sensor:    sensor.FindByAntennaAlias(history.Location),
+			Timestamp: history.Timestamp,
+		})
+	}
+	if len(steps) > 0 {
+		ctx.To = steps[0].Sensor
+	}
+	if len(steps) > 1 {
+		ctx.From = steps[1].Sensor
+	}
+	ctx.Direction = direction.Infer(steps, now, int64(config.AppConfig.MaxTransitionTime)*1000)
+	if ctx.FacilityID == "" && ctx.To != nil {
+		ctx.FacilityID = ctx.To.FacilityId
+	}
+	return ctx
+}
 
-	return nil
+// ExpressionResult is the result of evaluating a rule expression against a single tag
+type ExpressionResult struct {
+	EPC       string `json:"epc"`
+	ProductID string `json:"product_id"`
+	Result    bool   `json:"result"`
+	Error     string `json:"error,omitempty"`
 }
 
-func triggerRecord(edgexcontext *appcontext.Context, tag *Tag) {
-	timestamp := helper.UnixMilliNow()
-	folderName := fmt.Sprintf(videoFolderPattern, timestamp, tag.ProductID, tag.Epc)
-	logrus.Debugf("recording filename: %s/video%s", folderName, config.AppConfig.VideoOutputExtension)
+// EvaluateExpression evaluates an expression against every tag in the payload, using the current sensor registry.
+// It has no side effects, and is used to test an expression before adding it to a rule.
+func EvaluateExpression(program *expression.Program, payload *DataPayload) []ExpressionResult {
+	timestamp := clock.Now()
+
+	tags, invalid := payload.validTags(timestamp)
+	results := make([]ExpressionResult, 0, len(payload.TagEvent))
+	for _, dropped := range invalid {
+		results = append(results, ExpressionResult{EPC: dropped.decision.EPC, ProductID: dropped.decision.ProductID, Error: dropped.decision.Reason})
+	}
+	for i := range tags {
+		tag := &tags[i]
+		result := ExpressionResult{EPC: tag.Epc, ProductID: tag.ProductID}
 
-	if recorded, err := camera.RecordVideoToDisk(config.AppConfig.VideoDevice, float64(config.AppConfig.RecordingDuration), folderName, config.AppConfig.LiveView); err != nil {
-		logrus.Warningf("unable to send EdgeX notification: %+v", err)
+		var err error
+		if result.Result, err = program.EvalBool(newRuleContext(tag, timestamp).Env()); err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
 
-	} else if recorded {
-		format := `
-An item was detected leaving. A video clip has been recorded for loss prevention purposes.
+// notifyWithoutRecording stores an incident for tags whose rule only notifies, and sends the notification right away.
+// It returns the id of the incident.
+func notifyWithoutRecording(edgexcontext *appcontext.Context, timestamp int64, tags []incident.Tag) string {
+	inc := incident.NewIncident(timestamp, "")
+	addTags(inc, tags)
+	if err := incident.Save(inc); err != nil {
+		logrus.Errorf("unable to save incident %s: %v", inc.ID, err)
+	}
+	notifyIncident(edgexcontext, inc)
+	return inc.ID
+}
 
- Timestamp: %d
-Product ID: %s
-       EPC: %s
+// recordPOSRead remembers tags currently being read by a POS personality sensor, as they are most likely being sold
+func recordPOSRead(tag *Tag, now int64, saleWindow int64) {
+	if len(tag.LocationHistory) == 0 {
+		return
+	}
 
+	rsp := sensor.FindByAntennaAlias(tag.LocationHistory[0].Location)
+	if rsp == nil || !rsp.IsPOSSensor() {
+		return
+	}
+
+	timestamp := tag.LocationHistory[0].Timestamp
+	if timestamp == 0 {
+		timestamp = now
+	}
+	logrus.Debugf("tag read at POS sensor: epc: %s (sku: %s)", tag.Epc, tag.ProductID)
+	pos.RecordSensorRead(tag.Epc, timestamp, now, saleWindow)
+}
+
+func notifyIncident(edgexcontext *appcontext.Context, inc *incident.Incident) {
+	if incidentHandler != nil {
+		incidentHandler(inc)
+		return
+	}
+
+	format := `
+%s
+
+  Incident: %s
+ Timestamp: %d
+%s%s
 `
-		content := fmt.Sprintf(format, timestamp, tag.ProductID, tag.Epc)
+	summary := fmt.Sprintf("%d item(s) detected leaving. A video clip has been recorded for loss prevention purposes.", len(inc.Tags))
+	details := fmt.Sprintf("    Camera: %s\n", inc.Camera)
+	// incidents of rules which only notify are not recorded
+	if inc.Camera == "" {
+		summary = fmt.Sprintf("%d item(s) detected matching a loss prevention rule.", len(inc.Tags))
+		details = ""
+	} else if len(inc.Recordings) == 0 {
+		summary = fmt.Sprintf("%d item(s) detected leaving. The video clip could not be recorded.", len(inc.Tags))
+	}
+	if inc.Risk != nil {
+		details += fmt.Sprintf("      Risk: %.0f (%s)\n", inc.Risk.Score, strings.Join(inc.Risk.Factors, ", "))
+	}
 
-		if err := notification.PostNotification(edgexcontext, content); err != nil {
-			logrus.Error(err)
+	var items strings.Builder
+	for _, tag := range inc.Tags {
+		if tag.ProductName != "" {
+			fmt.Fprintf(&items, "\n   Product: %s", tag.ProductName)
+		}
+		fmt.Fprintf(&items, `
+Product ID: %s
+       EPC: %s
+      Rule: %s
+`, tag.ProductID, tag.EPC, tag.Rule)
+		if tag.GTIN != "" {
+			fmt.Fprintf(&items, "      GTIN: %s\n", tag.GTIN)
 		}
+		if len(tag.Lists) > 0 {
+			fmt.Fprintf(&items, "     Lists: %s\n", strings.Join(tag.Lists, ", "))
+		}
+		if tag.LowRisk {
+			fmt.Fprintf(&items, "  Low Risk: %s\n", incident.FlagRecentlySold)
+		}
+		if tag.Escalated {
+			fmt.Fprintf(&items, " Escalated: %s\n", incident.FlagFittingRoom)
+		}
+	}
+	content := fmt.Sprintf(format, summary, inc.ID, inc.Timestamp, details, items.String())
 
+	if err := notification.PostNotification(edgexcontext, inc.Severity(), content); err != nil {
+		logrus.Error(err)
 	}
 }
@@ -0,0 +1,92 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package lossprevention
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/decision"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/incident"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/camera"
+	"reflect"
+	"testing"
+)
+
+func triggeredResult(epc string, action string, cameras ...*camera.Camera) evaluation {
+	result := evaluation{
+		decision: decision.Decision{EPC: epc, Action: action},
+		tag:      incident.Tag{EPC: epc},
+		cameras:  cameras,
+	}
+	for _, cam := range cameras {
+		result.decision.Cameras = append(result.decision.Cameras, cam.Name)
+	}
+	result.decision.Accept("%s by rule test", action)
+	return result
+}
+
+func TestPlanIncidents(t *testing.T) {
+	defer func(risk *config.RiskConfig) { config.AppConfig.Risk = risk }(config.AppConfig.Risk)
+	// each tag scores a point, and it takes two to record
+	config.AppConfig.Risk = &config.RiskConfig{QuantityWeight: 1, RecordScore: 2, CriticalScore: 10}
+
+	front := camera.NewCamera(config.CameraConfig{Name: "front"})
+	side := camera.NewCamera(config.CameraConfig{Name: "side"})
+	back := camera.NewCamera(config.CameraConfig{Name: "back"})
+
+	results := []evaluation{
+		// recorded on the front camera, but too low risk on its own on the side camera
+		triggeredResult("E1", config.RuleActionRecord, front, side),
+		triggeredResult("E2", config.RuleActionRecord, front),
+		// too low risk to record on the back camera
+		triggeredResult("E3", config.RuleActionRecord, back),
+		triggeredResult("E4", config.RuleActionNotify),
+		{decision: decision.Decision{EPC: "E5", Stage: decision.StageCooldown}},
+	}
+	plan := planIncidents(results, 1571234567890)
+
+	if !reflect.DeepEqual(plan.cameras, []*camera.Camera{front}) {
+		t.Errorf("expected to record only on the front camera, but got %+v", plan.cameras)
+	}
+	if epcs := epcsOfTags(plan.triggered[front]); !reflect.DeepEqual(epcs, []string{"E1", "E2"}) {
+		t.Errorf("expected E1 and E2 to be recorded on the front camera, but got %v", epcs)
+	}
+	if epcs := epcsOfTags(plan.notified); !reflect.DeepEqual(epcs, []string{"E3", "E4"}) {
+		t.Errorf("expected only E3 and E4 to be notified, once each, but got %v", epcs)
+	}
+
+	tests := []struct {
+		epc     string
+		action  string
+		cameras []string
+		score   float64
+	}{
+		{"E1", config.RuleActionRecord, []string{"front"}, 2},
+		{"E2", config.RuleActionRecord, []string{"front"}, 2},
+		{"E3", config.RuleActionNotify, nil, 2},
+		{"E4", config.RuleActionNotify, nil, 2},
+	}
+	for i, test := range tests {
+		d := results[i].decision
+		if d.Action != test.action || !reflect.DeepEqual(d.Cameras, test.cameras) {
+			t.Errorf("%s: expected %s on %v, but got %s on %v", test.epc, test.action, test.cameras, d.Action, d.Cameras)
+		}
+		if d.Risk == nil || d.Risk.Score != test.score {
+			t.Errorf("%s: expected a risk score of %v, but got %+v", test.epc, test.score, d.Risk)
+		}
+	}
+	if results[4].decision.Risk != nil {
+		t.Errorf("expected a rejected tag to have no risk score, but got %+v", results[4].decision.Risk)
+	}
+}
+
+func epcsOfTags(tags []incident.Tag) []string {
+	var epcs []string
+	for _, tag := range tags {
+		epcs = append(epcs, tag.EPC)
+	}
+	return epcs
+}
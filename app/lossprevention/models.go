@@ -6,7 +6,31 @@
 
 package lossprevention
 
-import "time"
+import (
+	"fmt"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/decision"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/jsonrpc"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	arrival    = "arrival"
+	departed   = "departed"
+	cycleCount = "cycle_count"
+)
+
+var (
+	epcRegex = regexp.MustCompile("^[0-9A-Fa-f]+$")
+	// every event the RSP Controller can send for a tag
+	validEvents = map[string]bool{
+		arrival:    true,
+		moved:      true,
+		departed:   true,
+		cycleCount: true,
+	}
+)
 
 type DataPayload struct {
 	ControllerId       string `json:"device_id"` //backend expects this to be "device_id" instead of controller_id
@@ -64,8 +88,92 @@ type LocationHistory struct {
 	Source    string `json:"source"`
 }
 
-// Validate implements the jsonrpc.Message interface
+// Validate implements the jsonrpc.Message interface. Only the header fields are checked, as an invalid tag only
+// drops the tag itself, see validTags.
 func (dataPayload *DataPayload) Validate() error {
-	// todo
+	if dataPayload.ControllerId == "" {
+		return jsonrpc.NewValidationError(jsonrpc.ReasonMissingField, "device_id", "missing device_id field")
+	}
+	if dataPayload.SentOn <= 0 {
+		return jsonrpc.NewValidationError(jsonrpc.ReasonMissingField, "sent_on", "missing sent_on field")
+	}
+	if dataPayload.TotalEventSegments < 0 {
+		return jsonrpc.NewValidationError(jsonrpc.ReasonInvalidValue, "total_event_segments",
+			"total_event_segments must not be negative, but got %d", dataPayload.TotalEventSegments)
+	}
+	if dataPayload.TotalEventSegments > 1 &&
+		(dataPayload.EventSegmentNumber < 1 || dataPayload.EventSegmentNumber > dataPayload.TotalEventSegments) {
+		return jsonrpc.NewValidationError(jsonrpc.ReasonInvalidValue, "event_segment_number",
+			"event_segment_number must be between 1 and %d, but got %d", dataPayload.TotalEventSegments, dataPayload.EventSegmentNumber)
+	}
+	return nil
+}
+
+// invalidTag is a tag dropped from a payload, along with why
+type invalidTag struct {
+	decision decision.Decision
+	err      error
+}
+
+// validTags returns the valid tags of the payload, each with its location history ordered from newest to oldest,
+// along with every tag which was dropped. The payload itself is left unchanged.
+func (dataPayload *DataPayload) validTags(timestamp int64) ([]Tag, []invalidTag) {
+	valid := make([]Tag, 0, len(dataPayload.TagEvent))
+	var invalid []invalidTag
+	for i, tag := range dataPayload.TagEvent {
+		if err := tag.validate(fmt.Sprintf("data[%d]", i)); err != nil {
+			d := newDecision(&tag, timestamp)
+			d.Reject(decision.StageValidation, "invalid tag, %v", err)
+			invalid = append(invalid, invalidTag{decision: d, err: err})
+			continue
+		}
+		tag.LocationHistory = newestFirst(tag.LocationHistory)
+		valid = append(valid, tag)
+	}
+	return valid, invalid
+}
+
+// newestFirst returns the location history ordered from the most recent location to the oldest. The RSP
+// Controller already sends it in that order, so it is only copied and sorted when it is not.
+func newestFirst(history []LocationHistory) []LocationHistory {
+	newer := func(i, j int) bool {
+		return history[i].Timestamp > history[j].Timestamp
+	}
+	if sort.SliceIsSorted(history, newer) {
+		return history
+	}
+
+	history = append([]LocationHistory(nil), history...)
+	sort.SliceStable(history, newer)
+	return history
+}
+
+// validate checks a single tag of a DataPayload. field is the path to the tag, used in error messages.
+func (tag *Tag) validate(field string) error {
+	if tag.Epc == "" {
+		return jsonrpc.NewValidationError(jsonrpc.ReasonMissingField, field+".epc", "missing epc field")
+	}
+	if !epcRegex.MatchString(tag.Epc) {
+		return jsonrpc.NewValidationError(jsonrpc.ReasonInvalidFormat, field+".epc", "epc must be hexadecimal, but got %s", tag.Epc)
+	}
+	if tag.ProductID == "" {
+		return jsonrpc.NewValidationError(jsonrpc.ReasonMissingField, field+".product_id", "missing product_id field for epc %s", tag.Epc)
+	}
+	if tag.Event == "" {
+		return jsonrpc.NewValidationError(jsonrpc.ReasonMissingField, field+".event", "missing event field for epc %s", tag.Epc)
+	}
+	if !validEvents[tag.Event] {
+		return jsonrpc.NewValidationError(jsonrpc.ReasonInvalidValue, field+".event", "unknown event %s for epc %s", tag.Event, tag.Epc)
+	}
+
+	for i, history := range tag.LocationHistory {
+		historyField := fmt.Sprintf("%s.location_history[%d]", field, i)
+		if history.Location == "" {
+			return jsonrpc.NewValidationError(jsonrpc.ReasonMissingField, historyField+".location", "missing location field for epc %s", tag.Epc)
+		}
+		if history.Timestamp <= 0 {
+			return jsonrpc.NewValidationError(jsonrpc.ReasonMissingField, historyField+".timestamp", "missing timestamp field for epc %s", tag.Epc)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,186 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package lossprevention
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/decision"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/jsonrpc"
+	"testing"
+)
+
+func validPayload() *DataPayload {
+	return &DataPayload{
+		ControllerId:       "rrs-gateway",
+		SentOn:             1571234567890,
+		TotalEventSegments: 1,
+		EventSegmentNumber: 1,
+		TagEvent: []Tag{
+			{
+				Epc:       "3014AB12CD34EF5600000001",
+				ProductID: "012345678905",
+				Event:     moved,
+				LocationHistory: []LocationHistory{
+					{Location: "RSP-150000-0", Timestamp: 1571234567000},
+					{Location: "RSP-150001-0", Timestamp: 1571234560000},
+				},
+			},
+		},
+	}
+}
+
+func TestDataPayloadValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(payload *DataPayload)
+		reason string
+		field  string
+	}{
+		{
+			name:   "valid",
+			modify: func(payload *DataPayload) {},
+		},
+		{
+			name:   "missing device id",
+			modify: func(payload *DataPayload) { payload.ControllerId = "" },
+			reason: jsonrpc.ReasonMissingField,
+			field:  "device_id",
+		},
+		{
+			name:   "missing sent on",
+			modify: func(payload *DataPayload) { payload.SentOn = 0 },
+			reason: jsonrpc.ReasonMissingField,
+			field:  "sent_on",
+		},
+		{
+			name: "segment number out of range",
+			modify: func(payload *DataPayload) {
+				payload.TotalEventSegments = 3
+				payload.EventSegmentNumber = 4
+			},
+			reason: jsonrpc.ReasonInvalidValue,
+			field:  "event_segment_number",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload := validPayload()
+			test.modify(payload)
+
+			err := payload.Validate()
+			if test.reason == "" {
+				if err != nil {
+					t.Fatalf("Expected payload to be valid, but got %v", err)
+				}
+				return
+			}
+
+			validationErr, ok := err.(*jsonrpc.ValidationError)
+			if !ok {
+				t.Fatalf("Expected a ValidationError, but got %v", err)
+			}
+			if validationErr.Reason != test.reason || validationErr.Field != test.field {
+				t.Errorf("Expected %s on %s, but got %s on %s", test.reason, test.field, validationErr.Reason, validationErr.Field)
+			}
+		})
+	}
+}
+
+func TestDataPayloadValidTags(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(tag *Tag)
+		reason string
+		field  string
+	}{
+		{
+			name:   "missing epc",
+			modify: func(tag *Tag) { tag.Epc = "" },
+			reason: jsonrpc.ReasonMissingField,
+			field:  "data[1].epc",
+		},
+		{
+			name:   "epc is not hex",
+			modify: func(tag *Tag) { tag.Epc = "3014XYZ" },
+			reason: jsonrpc.ReasonInvalidFormat,
+			field:  "data[1].epc",
+		},
+		{
+			name:   "missing product id",
+			modify: func(tag *Tag) { tag.ProductID = "" },
+			reason: jsonrpc.ReasonMissingField,
+			field:  "data[1].product_id",
+		},
+		{
+			name:   "unknown event",
+			modify: func(tag *Tag) { tag.Event = "teleported" },
+			reason: jsonrpc.ReasonInvalidValue,
+			field:  "data[1].event",
+		},
+		{
+			name:   "empty location",
+			modify: func(tag *Tag) { tag.LocationHistory[1].Location = "" },
+			reason: jsonrpc.ReasonMissingField,
+			field:  "data[1].location_history[1].location",
+		},
+		{
+			name:   "missing location timestamp",
+			modify: func(tag *Tag) { tag.LocationHistory[0].Timestamp = 0 },
+			reason: jsonrpc.ReasonMissingField,
+			field:  "data[1].location_history[0].timestamp",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload := validPayload()
+			bad := validPayload().TagEvent[0]
+			test.modify(&bad)
+			payload.TagEvent = append(payload.TagEvent, bad)
+
+			tags, invalid := payload.validTags(1571234567890)
+			if len(invalid) != 1 {
+				t.Fatalf("Expected 1 invalid tag, but got %d", len(invalid))
+			}
+			validationErr, ok := invalid[0].err.(*jsonrpc.ValidationError)
+			if !ok {
+				t.Fatalf("Expected a ValidationError, but got %v", invalid[0].err)
+			}
+			if validationErr.Reason != test.reason || validationErr.Field != test.field {
+				t.Errorf("Expected %s on %s, but got %s on %s", test.reason, test.field, validationErr.Reason, validationErr.Field)
+			}
+			if invalid[0].decision.Stage != decision.StageValidation || invalid[0].decision.Triggered {
+				t.Errorf("Expected the invalid tag to be rejected at the validation stage, but got %+v", invalid[0].decision)
+			}
+
+			// the invalid tag is dropped, but the valid one is still processed, and the payload is left unchanged
+			if len(tags) != 1 || tags[0].Epc != validPayload().TagEvent[0].Epc {
+				t.Errorf("Expected only the valid tag to be kept, but got %+v", tags)
+			}
+			if len(payload.TagEvent) != 2 {
+				t.Errorf("Expected the payload to keep both tags, but got %d", len(payload.TagEvent))
+			}
+		})
+	}
+}
+
+func TestDataPayloadValidTagsSortsLocationHistory(t *testing.T) {
+	payload := validPayload()
+	history := payload.TagEvent[0].LocationHistory
+	history[0], history[1] = history[1], history[0]
+
+	tags, invalid := payload.validTags(1571234567890)
+	if len(invalid) != 0 || len(tags) != 1 {
+		t.Fatalf("Expected a tag with an unordered location history to be kept, but got %d invalid tag(s)", len(invalid))
+	}
+	if sorted := tags[0].LocationHistory; sorted[0].Location != "RSP-150000-0" || sorted[1].Location != "RSP-150001-0" {
+		t.Errorf("Expected the location history to be ordered from newest to oldest, but got %+v", sorted)
+	}
+	if history[0].Location != "RSP-150001-0" {
+		t.Errorf("Expected the location history of the payload to be left unchanged, but got %+v", history)
+	}
+}
@@ -0,0 +1,287 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package lossprevention
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/edgexfoundry/app-functions-sdk-go/appcontext"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/incident"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/risk"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/camera"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/clock"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	metadataFilename = "metadata.json"
+	fileMode         = 0777
+)
+
+var (
+	cameraQueues      = make(map[string]*cameraQueue)
+	cameraQueuesMutex sync.Mutex
+
+	recorder         Recorder = cameraRecorder{}
+	recordingsFolder          = "/recordings"
+)
+
+// Recorder records the video of incidents. It can be replaced to replay captured events without the cameras.
+type Recorder interface {
+	// Record records seconds of video of the camera into the folder, including the pre-roll before triggeredOn.
+	// It blocks until the recording, including any extension of it, is done.
+	Record(cam *camera.Camera, triggeredOn int64, seconds float64, folderName string) (*camera.Metadata, error)
+	// Extend adds seconds to the recording in progress on the camera. It returns false if the camera is not
+	// recording anymore.
+	Extend(cam *camera.Camera, seconds float64) bool
+}
+
+// cameraRecorder records from the cameras themselves
+type cameraRecorder struct{}
+
+func (cameraRecorder) Record(cam *camera.Camera, triggeredOn int64, seconds float64, folderName string) (*camera.Metadata, error) {
+	// anything else recording on the camera, such as the startup sanity check, is waited for
+	return camera.WaitAndRecordVideoToDisk(cam, triggeredOn, seconds, folderName, config.AppConfig.LiveView)
+}
+
+func (cameraRecorder) Extend(cam *camera.Camera, seconds float64) bool {
+	return cam.ExtendRecording(seconds)
+}
+
+// SetRecorder replaces what records the incidents, and the folder the recordings are written to. It must be
+// called before any events are handled. A nil recorder goes back to recording from the cameras into /recordings.
+func SetRecorder(r Recorder, folder string) {
+	if r == nil {
+		r, folder = cameraRecorder{}, "/recordings"
+	}
+	recorder, recordingsFolder = r, folder
+}
+
+// UnfinishedRecordings returns how many incidents of the camera are waiting to be recorded, being recorded,
+// or being stored and notified
+func UnfinishedRecordings(cameraName string) int {
+	cameraQueuesMutex.Lock()
+	queue, ok := cameraQueues[cameraName]
+	cameraQueuesMutex.Unlock()
+	if !ok {
+		return 0
+	}
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return queue.unfinished
+}
+
+// session is a single recording on a camera, along with the incident it belongs to
+type session struct {
+	edgexcontext *appcontext.Context
+	incident     *incident.Incident
+}
+
+// cameraQueue serializes the recordings of a single camera. Triggers that arrive while the camera is
+// recording are either added to the recording in progress (extend mode) or queued up as their own
+// recording (queue mode). Either way every triggering tag ends up in an incident.
+type cameraQueue struct {
+	cam *camera.Camera
+
+	mutex   sync.Mutex
+	active  *session
+	pending []*session
+	// number of sessions which are pending, active, or being finished
+	unfinished int
+	wake       chan struct{}
+}
+
+func getOrStartCameraQueue(cam *camera.Camera) *cameraQueue {
+	cameraQueuesMutex.Lock()
+	defer cameraQueuesMutex.Unlock()
+
+	queue, ok := cameraQueues[cam.Name]
+	if !ok {
+		queue = &cameraQueue{
+			cam:  cam,
+			wake: make(chan struct{}, 1),
+		}
+		cameraQueues[cam.Name] = queue
+		go queue.run()
+	}
+	return queue
+}
+
+// trigger accounts for the tags in an incident on this camera, based on the configured recording mode.
+// It returns the id of the incident the tags were added to.
+func (queue *cameraQueue) trigger(edgexcontext *appcontext.Context, timestamp int64, tags []incident.Tag) string {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if config.AppConfig.RecordingMode == config.RecordingModeExtend && queue.active != nil &&
+		recorder.Extend(queue.cam, float64(risk.RecordingDuration(risk.Assess(tags, timestamp)))) {
+		logrus.Debugf("extending recording in progress on camera %s", queue.cam.Name)
+		addTags(queue.active.incident, tags)
+		return queue.active.incident.ID
+	}
+
+	// in extend mode, anything waiting to be recorded is recorded together
+	if len(queue.pending) > 0 && (config.AppConfig.RecordingMode == config.RecordingModeExtend ||
+		len(queue.pending) >= config.AppConfig.RecordingQueueSize) {
+		logrus.Debugf("adding tags to the last pending recording on camera %s", queue.cam.Name)
+		last := queue.pending[len(queue.pending)-1]
+		addTags(last.incident, tags)
+		return last.incident.ID
+	}
+
+	inc := incident.NewIncident(timestamp, queue.cam.Name)
+	addTags(inc, tags)
+	if err := incident.Save(inc); err != nil {
+		logrus.Errorf("unable to save incident %s: %v", inc.ID, err)
+	}
+	queue.pending = append(queue.pending, &session{edgexcontext: edgexcontext, incident: inc})
+	queue.unfinished++
+
+	select {
+	case queue.wake <- struct{}{}:
+	default:
+	}
+	return inc.ID
+}
+
+// addTags adds tags to an incident, and scores the risk of the incident again
+func addTags(inc *incident.Incident, tags []incident.Tag) {
+	inc.AddTags(tags...)
+	inc.Risk = risk.Assess(inc.Tags, inc.Timestamp)
+}
+
+// run records every pending session one after another
+func (queue *cameraQueue) run() {
+	for range queue.wake {
+		for {
+			queue.mutex.Lock()
+			if len(queue.pending) == 0 {
+				queue.mutex.Unlock()
+				break
+			}
+			queue.active = queue.pending[0]
+			queue.pending = queue.pending[1:]
+			queue.mutex.Unlock()
+
+			folderName, recording, err := queue.record(queue.active)
+
+			// once active is cleared, no more tags can be added to the incident
+			queue.mutex.Lock()
+			current := queue.active
+			queue.active = nil
+			queue.mutex.Unlock()
+
+			finishRecording(current, folderName, recording, err)
+
+			queue.mutex.Lock()
+			queue.unfinished--
+			queue.mutex.Unlock()
+		}
+	}
+}
+
+func (queue *cameraQueue) record(s *session) (string, *camera.Metadata, error) {
+	queue.mutex.Lock()
+	first := s.incident.Tags[0]
+	duration := risk.RecordingDuration(s.incident.Risk)
+	queue.mutex.Unlock()
+
+	folderName := filepath.Join(recordingsFolder, fmt.Sprintf(videoFolderPattern, s.incident.Timestamp, first.ProductID, first.EPC, queue.cam.Name))
+	logrus.Debugf("recording filename: %s/video%s", folderName, config.AppConfig.VideoOutputExtension)
+
+	recording, err := recorder.Record(queue.cam, s.incident.Timestamp, float64(duration), folderName)
+	return folderName, recording, err
+}
+
+// finishRecording stores the final state of the incident, writes its metadata alongside the recording
+// and notifies subscribers, whether or not the recording succeeded
+func finishRecording(s *session, folderName string, recording *camera.Metadata, err error) {
+	recorded := recording != nil
+	if err != nil {
+		logrus.Errorf("unable to record incident %s on camera %s: %+v, tags: %+v", s.incident.ID, s.incident.Camera, err, s.incident.Tags)
+	} else if !recorded {
+		logrus.Warnf("incident %s on camera %s was not recorded, tags: %+v", s.incident.ID, s.incident.Camera, s.incident.Tags)
+	} else {
+		s.incident.Recordings = []string{filepath.Base(folderName)}
+		s.incident.Detections = recording.Crops()
+	}
+
+	// tags may have been added while recording, and a reviewer may have already changed the status
+	// or repeats may have been counted in the meantime
+	updated, updateErr := incident.Update(s.incident.ID, clock.Now(), func(stored *incident.Incident) error {
+		repeats := make(map[string]int)
+		for _, tag := range stored.Tags {
+			repeats[tag.EPC] = tag.Repeats
+		}
+		stored.Tags = s.incident.Tags
+		for i := range stored.Tags {
+			if stored.Tags[i].Repeats < repeats[stored.Tags[i].EPC] {
+				stored.Tags[i].Repeats = repeats[stored.Tags[i].EPC]
+			}
+		}
+		stored.Recordings = s.incident.Recordings
+		stored.Detections = s.incident.Detections
+		return nil
+	})
+	if updateErr != nil {
+		logrus.Errorf("unable to update incident %s: %v", s.incident.ID, updateErr)
+		updated = s.incident
+	}
+
+	// subscribers are still alerted of the tags when the recording failed
+	if err != nil || !recorded {
+		notifyIncident(s.edgexcontext, updated)
+		return
+	}
+
+	if err := writeMetadata(folderName, RecordingMetadata{Incident: updated, Recording: recording}); err != nil {
+		logrus.Errorf("unable to write incident metadata: %v", err)
+	}
+
+	notifyIncident(s.edgexcontext, updated)
+}
+
+// RecordingMetadata is written alongside every recording, and describes both the incident it belongs to and
+// what was recorded
+type RecordingMetadata struct {
+	Incident  *incident.Incident `json:"incident"`
+	Recording *camera.Metadata   `json:"recording"`
+}
+
+func writeMetadata(folderName string, metadata RecordingMetadata) error {
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(folderName, fileMode); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(folderName, metadataFilename), data, fileMode)
+}
+
+// ReadMetadata reads the metadata written alongside a recording
+func ReadMetadata(folderName string) (*RecordingMetadata, error) {
+	data, err := ioutil.ReadFile(filepath.Join(folderName, metadataFilename))
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := new(RecordingMetadata)
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, err
+	}
+	if metadata.Incident == nil || metadata.Recording == nil {
+		return nil, fmt.Errorf("recording metadata %s is missing the incident or recording", folderName)
+	}
+	return metadata, nil
+}
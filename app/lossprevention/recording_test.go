@@ -0,0 +1,73 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package lossprevention
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/incident"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/camera"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/tracker"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recordings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	inc := incident.NewIncident(1000, "front")
+	inc.AddTags(incident.Tag{EPC: "30140000", ProductID: "00111111"})
+	recording := &camera.Metadata{
+		Camera:          "front",
+		Video:           "video.mp4",
+		Thumb:           "thumb.jpg",
+		FPS:             25,
+		FrameTimestamps: []int64{960, 1000},
+		Detections: []camera.DetectionMetadata{
+			{Label: "face", Confidence: 1, Box: tracker.Box{X: 10, Y: 20, Width: 30, Height: 30}, Frame: 0, Track: 1},
+			{Label: "face", Confidence: 1, Box: tracker.Box{X: 12, Y: 20, Width: 32, Height: 32}, Frame: 1, Track: 1, Crop: "face.1.jpg"},
+		},
+	}
+
+	folder := filepath.Join(dir, "1000_00111111_30140000_front")
+	if err := writeMetadata(folder, RecordingMetadata{Incident: inc, Recording: recording}); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := ReadMetadata(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Incident.ID != inc.ID || !reflect.DeepEqual(metadata.Incident.Tags, inc.Tags) {
+		t.Errorf("expected incident %+v, but got %+v", inc, metadata.Incident)
+	}
+	if !reflect.DeepEqual(metadata.Recording, recording) {
+		t.Errorf("expected recording %+v, but got %+v", recording, metadata.Recording)
+	}
+	if crops := metadata.Recording.Crops(); !reflect.DeepEqual(crops, []string{"face.1.jpg"}) {
+		t.Errorf("expected crops [face.1.jpg], but got %v", crops)
+	}
+
+	// written before recordings had any metadata besides the incident
+	older := filepath.Join(dir, "900_00111111_30140000")
+	if err := os.MkdirAll(older, fileMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(older, metadataFilename), []byte(`{"id": "abc"}`), fileMode); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadMetadata(older); err == nil {
+		t.Error("expected an error for metadata without a recording")
+	}
+	if _, err := ReadMetadata(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error for a missing folder, but got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package lossprevention
+
+import (
+	"fmt"
+	"github.com/edgexfoundry/app-functions-sdk-go/appcontext"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/clock"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	segmentSets      = make(map[string]*segmentSet)
+	segmentSetsMutex sync.Mutex
+)
+
+// segmentSet is every segment received so far of a single inventory event
+type segmentSet struct {
+	key          string
+	total        int
+	segments     map[int]*DataPayload
+	highest      int
+	edgexcontext *appcontext.Context
+	timer        clock.Timer
+}
+
+// HandleSegment buffers a segment of an inventory event until every segment of the event has been
+// received, and then evaluates the whole event at once. Events which are not segmented are evaluated
+// right away. If the remaining segments do not arrive within SegmentTimeout seconds, the segments
+// which did arrive are evaluated on their own.
+func HandleSegment(edgexcontext *appcontext.Context, payload *DataPayload) error {
+	if payload.TotalEventSegments <= 1 {
+		return HandleDataPayload(edgexcontext, payload)
+	}
+
+	complete, ok := addSegment(edgexcontext, payload, time.Duration(config.AppConfig.SegmentTimeout)*time.Second)
+	if !ok {
+		return nil
+	}
+	return HandleDataPayload(edgexcontext, complete)
+}
+
+// addSegment buffers the segment, returning the merged event once it is complete
+func addSegment(edgexcontext *appcontext.Context, payload *DataPayload, timeout time.Duration) (*DataPayload, bool) {
+	mInvalid := metrics.GetOrRegisterCounter("loss-prevention-service.HandleSegment.Invalid", nil)
+	mDuplicate := metrics.GetOrRegisterCounter("loss-prevention-service.HandleSegment.Duplicate", nil)
+	mOutOfOrder := metrics.GetOrRegisterCounter("loss-prevention-service.HandleSegment.OutOfOrder", nil)
+
+	number := payload.EventSegmentNumber
+	if number < 1 || number > payload.TotalEventSegments {
+		logrus.Warnf("dropping segment %d of %d from %s (sent on %d): invalid segment number", number, payload.TotalEventSegments, payload.ControllerId, payload.SentOn)
+		mInvalid.Inc(1)
+		return nil, false
+	}
+
+	segmentSetsMutex.Lock()
+	defer segmentSetsMutex.Unlock()
+
+	key := fmt.Sprintf("%s/%d", payload.ControllerId, payload.SentOn)
+	set, ok := segmentSets[key]
+	if !ok {
+		set = &segmentSet{
+			key:      key,
+			total:    payload.TotalEventSegments,
+			segments: make(map[int]*DataPayload),
+		}
+		set.timer = clock.AfterFunc(timeout, func() {
+			expireSegments(set)
+		})
+		segmentSets[key] = set
+	}
+
+	if payload.TotalEventSegments != set.total {
+		logrus.Warnf("dropping segment %d from %s (sent on %d): expected %d total segments, but got %d", number, payload.ControllerId, payload.SentOn, set.total, payload.TotalEventSegments)
+		mInvalid.Inc(1)
+		return nil, false
+	}
+	if _, ok := set.segments[number]; ok {
+		logrus.Warnf("dropping duplicate segment %d of %d from %s (sent on %d)", number, set.total, payload.ControllerId, payload.SentOn)
+		mDuplicate.Inc(1)
+		return nil, false
+	}
+	if number < set.highest {
+		logrus.Warnf("received segment %d of %d from %s (sent on %d) out of order, after segment %d", number, set.total, payload.ControllerId, payload.SentOn, set.highest)
+		mOutOfOrder.Inc(1)
+	} else {
+		set.highest = number
+	}
+
+	set.segments[number] = payload
+	set.edgexcontext = edgexcontext
+	if len(set.segments) < set.total {
+		return nil, false
+	}
+
+	set.timer.Stop()
+	delete(segmentSets, key)
+	return set.merge(), true
+}
+
+// expireSegments evaluates whatever segments of an event arrived before the timeout
+func expireSegments(set *segmentSet) {
+	payload, ok := takeIncomplete(set)
+	if !ok {
+		return
+	}
+
+	if err := HandleDataPayload(set.edgexcontext, payload); err != nil {
+		logrus.Errorf("unable to handle incomplete inventory event %s: %v", set.key, err)
+	}
+}
+
+// FlushSegments evaluates every event still waiting for segments right away, instead of waiting for the timeout
+func FlushSegments() {
+	segmentSetsMutex.Lock()
+	keys := make([]string, 0, len(segmentSets))
+	for key := range segmentSets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sets := make([]*segmentSet, 0, len(keys))
+	for _, key := range keys {
+		sets = append(sets, segmentSets[key])
+	}
+	segmentSetsMutex.Unlock()
+
+	for _, set := range sets {
+		set.timer.Stop()
+		expireSegments(set)
+	}
+}
+
+// takeIncomplete removes a segment set which has not been completed, returning the segments which did arrive
+func takeIncomplete(set *segmentSet) (*DataPayload, bool) {
+	mIncomplete := metrics.GetOrRegisterCounter("loss-prevention-service.HandleSegment.Incomplete", nil)
+
+	segmentSetsMutex.Lock()
+	defer segmentSetsMutex.Unlock()
+
+	// the set may have completed right as the timer fired
+	if segmentSets[set.key] != set {
+		return nil, false
+	}
+	delete(segmentSets, set.key)
+
+	var missing []int
+	for number := 1; number <= set.total; number++ {
+		if _, ok := set.segments[number]; !ok {
+			missing = append(missing, number)
+		}
+	}
+	logrus.Warnf("inventory event %s is incomplete, missing segments %v of %d", set.key, missing, set.total)
+	mIncomplete.Inc(1)
+
+	return set.merge(), true
+}
+
+// merge combines the tags of every segment into a single event, in segment order
+func (set *segmentSet) merge() *DataPayload {
+	numbers := make([]int, 0, len(set.segments))
+	for number := range set.segments {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	first := set.segments[numbers[0]]
+	merged := &DataPayload{
+		ControllerId:       first.ControllerId,
+		SentOn:             first.SentOn,
+		TotalEventSegments: set.total,
+	}
+	for _, number := range numbers {
+		merged.TagEvent = append(merged.TagEvent, set.segments[number].TagEvent...)
+	}
+	return merged
+}
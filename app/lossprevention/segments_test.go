@@ -0,0 +1,108 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package lossprevention
+
+import (
+	"testing"
+	"time"
+)
+
+func segment(sentOn int64, number int, total int, epcs ...string) *DataPayload {
+	payload := &DataPayload{
+		ControllerId:       "rrs-gateway",
+		SentOn:             sentOn,
+		TotalEventSegments: total,
+		EventSegmentNumber: number,
+	}
+	for _, epc := range epcs {
+		payload.TagEvent = append(payload.TagEvent, Tag{Epc: epc})
+	}
+	return payload
+}
+
+func epcsOf(payload *DataPayload) []string {
+	var epcs []string
+	for _, tag := range payload.TagEvent {
+		epcs = append(epcs, tag.Epc)
+	}
+	return epcs
+}
+
+func TestAddSegment(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []*DataPayload
+		expected []string
+	}{
+		{
+			name:     "in order",
+			segments: []*DataPayload{segment(1, 1, 3, "A", "B"), segment(1, 2, 3, "C"), segment(1, 3, 3, "D")},
+			expected: []string{"A", "B", "C", "D"},
+		},
+		{
+			name:     "out of order",
+			segments: []*DataPayload{segment(2, 3, 3, "D"), segment(2, 1, 3, "A"), segment(2, 2, 3, "B", "C")},
+			expected: []string{"A", "B", "C", "D"},
+		},
+		{
+			name:     "duplicate and invalid segments are dropped",
+			segments: []*DataPayload{segment(3, 1, 2, "A"), segment(3, 1, 2, "X"), segment(3, 5, 2, "Y"), segment(3, 2, 3, "Z"), segment(3, 2, 2, "B")},
+			expected: []string{"A", "B"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var complete *DataPayload
+			for i, payload := range test.segments {
+				merged, ok := addSegment(nil, payload, time.Hour)
+				if ok != (i == len(test.segments)-1) {
+					t.Fatalf("Expected segment %d to complete the event: %v, but got %v", i, !ok, ok)
+				}
+				complete = merged
+			}
+
+			epcs := epcsOf(complete)
+			if len(epcs) != len(test.expected) {
+				t.Fatalf("Expected tags %v, but got %v", test.expected, epcs)
+			}
+			for i := range epcs {
+				if epcs[i] != test.expected[i] {
+					t.Fatalf("Expected tags %v, but got %v", test.expected, epcs)
+				}
+			}
+		})
+	}
+
+	if len(segmentSets) != 0 {
+		t.Errorf("Expected every completed segment set to be removed, but %d remain", len(segmentSets))
+	}
+}
+
+func TestTakeIncomplete(t *testing.T) {
+	if _, ok := addSegment(nil, segment(10, 3, 3, "C"), time.Hour); ok {
+		t.Fatal("Expected event to be incomplete")
+	}
+	if _, ok := addSegment(nil, segment(10, 1, 3, "A"), time.Hour); ok {
+		t.Fatal("Expected event to be incomplete")
+	}
+
+	set := segmentSets["rrs-gateway/10"]
+	set.timer.Stop()
+
+	payload, ok := takeIncomplete(set)
+	if !ok {
+		t.Fatal("Expected incomplete segments to be returned")
+	}
+	if epcs := epcsOf(payload); len(epcs) != 2 || epcs[0] != "A" || epcs[1] != "C" {
+		t.Errorf("Expected tags [A C], but got %v", epcs)
+	}
+
+	if _, ok := takeIncomplete(set); ok {
+		t.Error("Expected segment set to only be taken once")
+	}
+}
@@ -25,11 +25,17 @@ const (
 	notificationSlug     = "loss-prevention-service"
 	subscriptionEndpoint = "/api/v1/subscription"
 	notificationCategory = "SECURITY"
-	notificationSeverity = "CRITICAL"
 	notificationLabel    = "LOSS-PREVENTION"
 	notificationSender   = "Loss Prevention App"
 )
 
+const (
+	// SeverityCritical is used for items leaving the store
+	SeverityCritical = "CRITICAL"
+	// SeverityNormal is used for lower priority alerts, such as items left in a fitting room
+	SeverityNormal = "NORMAL"
+)
+
 // Subscriber holds the body schema to register a subscriber to EdgeX
 type Subscriber struct {
 	Slug                 string     `json:"slug"`
@@ -47,7 +53,7 @@ type Channels struct {
 }
 
 // This leverages EdgeX Alerts & notification service
-func PostNotification(edgexcontext *appcontext.Context, content string) error {
+func PostNotification(edgexcontext *appcontext.Context, severity string, content string) error {
 
 	log.Info("Sending notification to EdgeX...")
 
@@ -56,7 +62,7 @@ func PostNotification(edgexcontext *appcontext.Context, content string) error {
 		Labels:   []string{notificationLabel},
 		Sender:   notificationSender,
 		Category: notificationCategory,
-		Severity: notificationSeverity,
+		Severity: severity,
 		Content:  content,
 	}
 
@@ -0,0 +1,111 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package pos
+
+import (
+	"fmt"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/jsonrpc"
+	"sync"
+)
+
+// Source describes how the service learned that an EPC was sold
+type Source string
+
+const (
+	// SourceSensor means the EPC was read by a POS personality sensor
+	SourceSensor Source = "pos_sensor"
+	// SourceTransaction means the EPC was reported as sold by the point-of-sale system
+	SourceTransaction Source = "transaction"
+
+	// how often old sales are pruned, in milliseconds
+	pruneInterval = 60 * 1000
+)
+
+var (
+	sales     = make(map[string]Sale)
+	mutex     sync.Mutex
+	lastPrune int64
+)
+
+// Sale is the most recent time an EPC was sold or passed a POS reader
+type Sale struct {
+	EPC           string `json:"epc"`
+	Timestamp     int64  `json:"timestamp"`
+	Source        Source `json:"source"`
+	TransactionID string `json:"transaction_id,omitempty"`
+}
+
+// SaleTransaction is a sale reported by the point-of-sale system, either over HTTP or through EdgeX
+type SaleTransaction struct {
+	TransactionID string   `json:"transaction_id"`
+	EPCs          []string `json:"epcs"`
+	// Time of the sale in milliseconds epoch. If omitted, the time it was received is used.
+	Timestamp int64 `json:"timestamp"`
+}
+
+// Validate implements the jsonrpc.Message interface
+func (transaction *SaleTransaction) Validate() error {
+	if len(transaction.EPCs) == 0 {
+		return jsonrpc.NewValidationError(jsonrpc.ReasonMissingField, "epcs", "missing epcs field")
+	}
+	for i, epc := range transaction.EPCs {
+		if epc == "" {
+			return jsonrpc.NewValidationError(jsonrpc.ReasonMissingField, fmt.Sprintf("epcs[%d]", i), "empty epc")
+		}
+	}
+	if transaction.Timestamp < 0 {
+		return jsonrpc.NewValidationError(jsonrpc.ReasonInvalidValue, "timestamp", "timestamp must not be negative, but got %d", transaction.Timestamp)
+	}
+	return nil
+}
+
+// RecordTransaction remembers every EPC in a sale transaction
+func RecordTransaction(transaction *SaleTransaction, now int64, window int64) {
+	timestamp := transaction.Timestamp
+	if timestamp == 0 {
+		timestamp = now
+	}
+	for _, epc := range transaction.EPCs {
+		record(Sale{EPC: epc, Timestamp: timestamp, Source: SourceTransaction, TransactionID: transaction.TransactionID}, now, window)
+	}
+}
+
+// RecordSensorRead remembers that an EPC was read by a POS personality sensor
+func RecordSensorRead(epc string, timestamp int64, now int64, window int64) {
+	record(Sale{EPC: epc, Timestamp: timestamp, Source: SourceSensor}, now, window)
+}
+
+// Lookup returns the sale of an EPC if it happened within window milliseconds of now
+func Lookup(epc string, now int64, window int64) (Sale, bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	sale, ok := sales[epc]
+	if !ok || now-sale.Timestamp > window {
+		return Sale{}, false
+	}
+	return sale, true
+}
+
+func record(sale Sale, now int64, window int64) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// keep the newest sale of each EPC
+	if existing, ok := sales[sale.EPC]; !ok || existing.Timestamp <= sale.Timestamp {
+		sales[sale.EPC] = sale
+	}
+
+	if now-lastPrune > pruneInterval {
+		lastPrune = now
+		for epc, existing := range sales {
+			if now-existing.Timestamp > window {
+				delete(sales, epc)
+			}
+		}
+	}
+}
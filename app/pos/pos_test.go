@@ -0,0 +1,75 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package pos
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/jsonrpc"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	const window = 1000
+
+	RecordTransaction(&SaleTransaction{TransactionID: "T1", EPCs: []string{"3014AA"}, Timestamp: 5000}, 5000, window)
+	RecordSensorRead("3014BB", 5500, 5500, window)
+
+	tests := []struct {
+		name     string
+		epc      string
+		now      int64
+		expected bool
+		source   Source
+	}{
+		{name: "sold", epc: "3014AA", now: 5900, expected: true, source: SourceTransaction},
+		{name: "sold window elapsed", epc: "3014AA", now: 6001, expected: false},
+		{name: "pos sensor", epc: "3014BB", now: 6000, expected: true, source: SourceSensor},
+		{name: "never sold", epc: "3014CC", now: 6000, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sale, ok := Lookup(test.epc, test.now, window)
+			if ok != test.expected {
+				t.Errorf("Expected %v, but got %v", test.expected, ok)
+			}
+			if ok && sale.Source != test.source {
+				t.Errorf("Expected source %s, but got %s", test.source, sale.Source)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		transaction SaleTransaction
+		reason      string
+	}{
+		{"valid", SaleTransaction{EPCs: []string{"3014AA"}}, ""},
+		{"missing epcs", SaleTransaction{}, jsonrpc.ReasonMissingField},
+		{"empty epc", SaleTransaction{EPCs: []string{"3014AA", ""}}, jsonrpc.ReasonMissingField},
+		{"negative timestamp", SaleTransaction{EPCs: []string{"3014AA"}, Timestamp: -1}, jsonrpc.ReasonInvalidValue},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.transaction.Validate()
+			if test.reason == "" {
+				if err != nil {
+					t.Error(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected a %s error", test.reason)
+			}
+			if reason := jsonrpc.FailureReason(err); reason != test.reason {
+				t.Errorf("Expected reason %s, but got %s", test.reason, reason)
+			}
+		})
+	}
+}
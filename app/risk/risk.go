@@ -0,0 +1,96 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package risk scores incidents by the value of their items, how many items there are, and the time of day
+package risk
+
+import (
+	"fmt"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/catalog"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/incident"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/notification"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/timeofday"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"time"
+)
+
+// Assess scores the tags of an incident at the given time in milliseconds epoch. It returns nil if risk
+// scoring is not configured.
+func Assess(tags []incident.Tag, timestamp int64) *incident.Risk {
+	if config.AppConfig.Risk == nil {
+		return nil
+	}
+	return Score(config.AppConfig.Risk, tags, time.Unix(0, timestamp*int64(time.Millisecond)))
+}
+
+// Score scores the tags of an incident which happened at time t. The price of each tag comes from the tag,
+// and its risk tier from the catalog.
+func Score(cfg *config.RiskConfig, tags []incident.Tag, t time.Time) *incident.Risk {
+	risk := &incident.Risk{Factors: []string{}}
+
+	value := 0.0
+	tiers := make(map[string]int)
+	for _, tag := range tags {
+		value += tag.Price
+		if product, ok := catalog.Lookup(tag.ProductID); ok && product.RiskTier != "" {
+			tiers[product.RiskTier]++
+		}
+	}
+	if value > 0 && cfg.ValueWeight != 0 {
+		risk.Score += value * cfg.ValueWeight
+		risk.Factors = append(risk.Factors, fmt.Sprintf("value %.2f x %v", value, cfg.ValueWeight))
+	}
+	names := make([]string, 0, len(tiers))
+	for tier := range tiers {
+		names = append(names, tier)
+	}
+	sort.Strings(names)
+	for _, tier := range names {
+		if points, ok := cfg.Tiers[tier]; ok && points != 0 {
+			risk.Score += float64(tiers[tier]) * points
+			risk.Factors = append(risk.Factors, fmt.Sprintf("%d %s risk item(s) x %v", tiers[tier], tier, points))
+		}
+	}
+	if cfg.QuantityWeight != 0 {
+		risk.Score += float64(len(tags)) * cfg.QuantityWeight
+		risk.Factors = append(risk.Factors, fmt.Sprintf("%d item(s) x %v", len(tags), cfg.QuantityWeight))
+	}
+	for _, hours := range cfg.Hours {
+		window, err := timeofday.ParseWindow(hours.StartTime, hours.EndTime)
+		if err != nil {
+			logrus.Warnf("skipping risk hours between %s and %s: %v", hours.StartTime, hours.EndTime, err)
+			continue
+		}
+		if window.Contains(t) {
+			risk.Score += hours.Points
+			risk.Factors = append(risk.Factors, fmt.Sprintf("between %s and %s +%v", hours.StartTime, hours.EndTime, hours.Points))
+		}
+	}
+
+	risk.Severity = notification.SeverityNormal
+	if risk.Score >= cfg.CriticalScore {
+		risk.Severity = notification.SeverityCritical
+	}
+	risk.Record = risk.Score >= cfg.RecordScore
+
+	best := 0.0
+	for _, duration := range cfg.RecordingDurations {
+		if risk.Score >= duration.MinScore && (risk.RecordingDuration == 0 || duration.MinScore > best) {
+			best, risk.RecordingDuration = duration.MinScore, duration.Seconds
+		}
+	}
+	return risk
+}
+
+// RecordingDuration returns how many seconds an incident with the given risk assessment is recorded for
+func RecordingDuration(risk *incident.Risk) int {
+	if risk != nil && risk.RecordingDuration > 0 {
+		return risk.RecordingDuration
+	}
+	return config.AppConfig.RecordingDuration
+}
@@ -0,0 +1,90 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package risk
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/catalog"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/incident"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/notification"
+	"testing"
+	"time"
+)
+
+func TestScore(t *testing.T) {
+	catalog.Replace(map[string]catalog.Product{
+		"jacket": {SKU: "jacket", Price: 200, RiskTier: "high"},
+		"socks":  {SKU: "socks", Price: 5},
+	})
+	defer catalog.Replace(map[string]catalog.Product{})
+
+	cfg := &config.RiskConfig{
+		ValueWeight:    0.1,
+		QuantityWeight: 5,
+		Tiers:          map[string]float64{"high": 30},
+		Hours:          []config.RiskHours{{StartTime: "22:00", EndTime: "06:00", Points: 20}},
+		CriticalScore:  50,
+		RecordScore:    10,
+		RecordingDurations: []config.RiskRecordingDuration{
+			{MinScore: 50, Seconds: 30},
+			{MinScore: 80, Seconds: 60},
+		},
+	}
+	jacket := incident.Tag{ProductID: "jacket", Price: 200}
+	socks := incident.Tag{ProductID: "socks", Price: 5}
+	day := time.Date(2019, 10, 16, 14, 0, 0, 0, time.Local)
+	night := time.Date(2019, 10, 16, 23, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		tags     []incident.Tag
+		t        time.Time
+		score    float64
+		severity string
+		record   bool
+		duration int
+	}{
+		// 20 value + 30 tier + 5 quantity
+		{"jacket", []incident.Tag{jacket}, day, 55, notification.SeverityCritical, true, 30},
+		// 0.5 value + 5 quantity
+		{"socks", []incident.Tag{socks}, day, 5.5, notification.SeverityNormal, false, 0},
+		// 1 value + 10 quantity + 20 hours
+		{"socks at night", []incident.Tag{socks, socks}, night, 31, notification.SeverityNormal, true, 0},
+		// 20.5 value + 30 tier + 10 quantity + 20 hours
+		{"everything at night", []incident.Tag{jacket, socks}, night, 80.5, notification.SeverityCritical, true, 60},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			risk := Score(cfg, test.tags, test.t)
+			if diff := risk.Score - test.score; diff > 0.001 || diff < -0.001 {
+				t.Errorf("expected score %v, but got %v: %v", test.score, risk.Score, risk.Factors)
+			}
+			if risk.Severity != test.severity {
+				t.Errorf("expected severity %s, but got %s", test.severity, risk.Severity)
+			}
+			if risk.Record != test.record {
+				t.Errorf("expected record: %v, but got %v", test.record, risk.Record)
+			}
+			if risk.RecordingDuration != test.duration {
+				t.Errorf("expected recording duration %d, but got %d", test.duration, risk.RecordingDuration)
+			}
+		})
+	}
+}
+
+func TestAssessDisabled(t *testing.T) {
+	config.AppConfig.Risk = nil
+	if risk := Assess([]incident.Tag{{ProductID: "socks"}}, 0); risk != nil {
+		t.Errorf("expected no assessment without a risk configuration, but got %+v", risk)
+	}
+
+	config.AppConfig.RecordingDuration = 15
+	if duration := RecordingDuration(nil); duration != 15 {
+		t.Errorf("expected the default recording duration, but got %d", duration)
+	}
+}
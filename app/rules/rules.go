@@ -0,0 +1,408 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package rules
+
+import (
+	"fmt"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/notification"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/direction"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/sensor"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/sgtin"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/timeofday"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var (
+	active      RuleSet
+	activeMutex sync.RWMutex
+)
+
+// Context is everything a rule can look at when deciding what happens to a tag
+type Context struct {
+	EPC       string
+	ProductID string
+	// Decoded EPC, nil if the EPC is not an SGTIN
+	SGTIN *sgtin.SGTIN
+	// Names of the filter lists the tag matched
+	Lists      []string
+	Event      string
+	FacilityID string
+	Confidence float64
+	// Sensor the tag was previously read at, nil if unknown
+	From *sensor.RSP
+	// Sensor the tag is currently read at, nil if unknown
+	To *sensor.RSP
+	// Direction inferred from the whole location history
+	Direction direction.Inference
+	// Time the tag is evaluated at
+	Time time.Time
+	// Tag and LocationHistory are the models as received from the RSP Controller, for use in expressions
+	Tag             interface{}
+	LocationHistory interface{}
+}
+
+// Env returns the variables available to rule expressions
+func (ctx *Context) Env() map[string]interface{} {
+	env := map[string]interface{}{
+		"tag":       ctx.Tag,
+		"history":   ctx.LocationHistory,
+		"from":      ctx.From,
+		"to":        ctx.To,
+		"sgtin":     ctx.SGTIN,
+		"direction": ctx.Direction,
+	}
+	for name, list := range config.AppConfig.Watchlists {
+		env[name] = list
+	}
+	return env
+}
+
+// Action is what happens to a tag once a rule matches
+type Action struct {
+	// One of config.RuleActionRecord, config.RuleActionNotify or config.RuleActionIgnore
+	Type     string
+	Severity string
+}
+
+// Rule decides whether or not it applies to a tag
+type Rule interface {
+	Name() string
+	Matches(ctx *Context) bool
+	Action() Action
+}
+
+// RuleSet is an ordered list of rules, where the first matching rule wins
+type RuleSet []Rule
+
+// Evaluate returns the first rule matching the tag
+func (ruleSet RuleSet) Evaluate(ctx *Context) (Rule, bool) {
+	for _, rule := range ruleSet {
+		if rule.Matches(ctx) {
+			return rule, true
+		}
+	}
+	return nil, false
+}
+
+// NewRuleSet compiles every rule in order
+func NewRuleSet(configs []config.RuleConfig) (RuleSet, error) {
+	ruleSet := make(RuleSet, 0, len(configs))
+	names := make(map[string]bool)
+	for i, cfg := range configs {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("rule at index %d is missing a name", i)
+		}
+		if names[cfg.Name] {
+			return nil, fmt.Errorf("rule name %s is defined more than once", cfg.Name)
+		}
+		names[cfg.Name] = true
+
+		rule, err := NewRule(cfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid rule %s", cfg.Name)
+		}
+		ruleSet = append(ruleSet, rule)
+	}
+	return ruleSet, nil
+}
+
+// SetupRules compiles the configured rules and makes them the active rule set
+func SetupRules() error {
+	ruleSet, err := NewRuleSet(config.AppConfig.Rules)
+	if err != nil {
+		return err
+	}
+
+	activeMutex.Lock()
+	active = ruleSet
+	activeMutex.Unlock()
+
+	logrus.Debugf("Configured rules: %+v", config.AppConfig.Rules)
+	return nil
+}
+
+// Active returns the active rule set
+func Active() RuleSet {
+	activeMutex.RLock()
+	defer activeMutex.RUnlock()
+	return active
+}
+
+// condition is a single compiled condition of a rule
+type condition func(ctx *Context) bool
+
+// conditionRule is a Rule built from a config.RuleConfig, which matches when all of its conditions match
+type conditionRule struct {
+	name       string
+	conditions []condition
+	action     Action
+}
+
+// NewRule compiles a single rule from its configuration
+func NewRule(cfg config.RuleConfig) (Rule, error) {
+	switch cfg.Action {
+	case config.RuleActionRecord, config.RuleActionNotify, config.RuleActionIgnore:
+	default:
+		return nil, fmt.Errorf("action must be one of %s, %s or %s, but got %q",
+			config.RuleActionRecord, config.RuleActionNotify, config.RuleActionIgnore, cfg.Action)
+	}
+
+	severity := cfg.Severity
+	if severity == "" {
+		severity = notification.SeverityCritical
+	}
+	if severity != notification.SeverityCritical && severity != notification.SeverityNormal {
+		return nil, fmt.Errorf("severity must be %s or %s, but got %q", notification.SeverityCritical, notification.SeverityNormal, cfg.Severity)
+	}
+
+	rule := &conditionRule{
+		name:   cfg.Name,
+		action: Action{Type: cfg.Action, Severity: severity},
+	}
+	if err := rule.compile(cfg.Conditions); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (rule *conditionRule) Name() string {
+	return rule.name
+}
+
+func (rule *conditionRule) Action() Action {
+	return rule.action
+}
+
+func (rule *conditionRule) Matches(ctx *Context) bool {
+	for _, matches := range rule.conditions {
+		if !matches(ctx) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rule *conditionRule) compile(conditions config.RuleConditions) error {
+	if len(conditions.Events) > 0 {
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return contains(conditions.Events, ctx.Event)
+		})
+	}
+
+	if len(conditions.FromPersonalities) > 0 {
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return ctx.From != nil && contains(conditions.FromPersonalities, string(ctx.From.Personality))
+		})
+	}
+	if len(conditions.NotFromPersonalities) > 0 {
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return ctx.From != nil && !contains(conditions.NotFromPersonalities, string(ctx.From.Personality))
+		})
+	}
+	if len(conditions.ToPersonalities) > 0 {
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return ctx.To != nil && contains(conditions.ToPersonalities, string(ctx.To.Personality))
+		})
+	}
+	if len(conditions.NotToPersonalities) > 0 {
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return ctx.To != nil && !contains(conditions.NotToPersonalities, string(ctx.To.Personality))
+		})
+	}
+
+	if len(conditions.SKUs) > 0 {
+		regexes, err := compileFilters(conditions.SKUs)
+		if err != nil {
+			return errors.Wrap(err, "invalid skus")
+		}
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return matchesAny(regexes, ctx.ProductID)
+		})
+	}
+	if len(conditions.EPCs) > 0 {
+		regexes, err := compileFilters(conditions.EPCs)
+		if err != nil {
+			return errors.Wrap(err, "invalid epcs")
+		}
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return matchesAny(regexes, ctx.EPC)
+		})
+	}
+
+	if len(conditions.GTINs) > 0 {
+		matchers, err := compileRangeFilters(conditions.GTINs)
+		if err != nil {
+			return errors.Wrap(err, "invalid gtins")
+		}
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return ctx.SGTIN != nil && matchesAnyFunc(matchers, ctx.SGTIN.GTIN)
+		})
+	}
+	if len(conditions.CompanyPrefixes) > 0 {
+		regexes, err := compileFilters(conditions.CompanyPrefixes)
+		if err != nil {
+			return errors.Wrap(err, "invalid company_prefixes")
+		}
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return ctx.SGTIN != nil && matchesAny(regexes, ctx.SGTIN.CompanyPrefix)
+		})
+	}
+	if len(conditions.Serials) > 0 {
+		matchers, err := compileRangeFilters(conditions.Serials)
+		if err != nil {
+			return errors.Wrap(err, "invalid serials")
+		}
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return ctx.SGTIN != nil && matchesAnyFunc(matchers, ctx.SGTIN.Serial)
+		})
+	}
+
+	if len(conditions.Lists) > 0 {
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			for _, name := range ctx.Lists {
+				if contains(conditions.Lists, name) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+
+	if len(conditions.Facilities) > 0 {
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return contains(conditions.Facilities, ctx.FacilityID)
+		})
+	}
+
+	if conditions.MinConfidence < 0 || conditions.MinConfidence > 1 {
+		return fmt.Errorf("min_confidence must be between 0 and 1, but got %v", conditions.MinConfidence)
+	}
+	if conditions.MinConfidence > 0 {
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return ctx.Confidence >= conditions.MinConfidence
+		})
+	}
+
+	if len(conditions.Directions) > 0 {
+		for _, name := range conditions.Directions {
+			if !isDirection(name) {
+				return fmt.Errorf("unknown direction %q, must be one of: %v", name, direction.Directions)
+			}
+		}
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return contains(conditions.Directions, string(ctx.Direction.Direction))
+		})
+	}
+	if conditions.MinDirectionConfidence < 0 || conditions.MinDirectionConfidence > 1 {
+		return fmt.Errorf("min_direction_confidence must be between 0 and 1, but got %v", conditions.MinDirectionConfidence)
+	}
+	if conditions.MinDirectionConfidence > 0 {
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return ctx.Direction.Confidence >= conditions.MinDirectionConfidence
+		})
+	}
+
+	if conditions.StartTime != "" || conditions.EndTime != "" {
+		start, err := timeofday.Parse(conditions.StartTime)
+		if err != nil {
+			return errors.Wrap(err, "invalid start_time")
+		}
+		end, err := timeofday.Parse(conditions.EndTime)
+		if err != nil {
+			return errors.Wrap(err, "invalid end_time")
+		}
+		window := timeofday.Window{Start: start, End: end}
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			return window.Contains(ctx.Time)
+		})
+	}
+
+	if conditions.Expression != "" {
+		program := conditions.Program()
+		if program == nil {
+			var err error
+			if program, err = config.CompileExpression(conditions.Expression); err != nil {
+				return errors.Wrap(err, "invalid expression")
+			}
+		}
+		rule.conditions = append(rule.conditions, func(ctx *Context) bool {
+			matches, err := program.EvalBool(ctx.Env())
+			if err != nil {
+				logrus.Warnf("unable to evaluate expression of rule %s for epc %s: %v", rule.name, ctx.EPC, err)
+				return false
+			}
+			return matches
+		})
+	}
+
+	return nil
+}
+
+func compileFilters(filters []string) ([]*regexp.Regexp, error) {
+	regexes := make([]*regexp.Regexp, 0, len(filters))
+	for _, filter := range filters {
+		regex, err := config.CompileFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		regexes = append(regexes, regex)
+	}
+	return regexes, nil
+}
+
+func compileRangeFilters(filters []string) ([]func(string) bool, error) {
+	matchers := make([]func(string) bool, 0, len(filters))
+	for _, filter := range filters {
+		matches, err := config.CompileRangeFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matches)
+	}
+	return matchers, nil
+}
+
+func matchesAnyFunc(matchers []func(string) bool, value string) bool {
+	for _, matches := range matchers {
+		if matches(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(regexes []*regexp.Regexp, value string) bool {
+	for _, regex := range regexes {
+		if regex.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDirection(name string) bool {
+	for _, d := range direction.Directions {
+		if string(d) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package rules
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/direction"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/sensor"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/sgtin"
+	"testing"
+	"time"
+)
+
+func rspWithPersonality(deviceId string, personality sensor.Personality) *sensor.RSP {
+	rsp := sensor.NewRSP(deviceId)
+	rsp.Personality = personality
+	return rsp
+}
+
+func TestDefaultRules(t *testing.T) {
+	ruleSet, err := NewRuleSet(config.DefaultRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exit := direction.Inference{Direction: direction.Exit, Confidence: 1}
+	ambiguous := direction.Inference{Direction: direction.Exit, Confidence: direction.AmbiguousConfidence}
+
+	tests := []struct {
+		name    string
+		ctx     Context
+		matches bool
+		action  string
+	}{
+		{
+			name:    "moved to exit",
+			ctx:     Context{Event: "moved", Direction: exit},
+			matches: true,
+			action:  config.RuleActionRecord,
+		},
+		{
+			name:    "ambiguous move to exit",
+			ctx:     Context{Event: "moved", Direction: ambiguous},
+			matches: true,
+			action:  config.RuleActionNotify,
+		},
+		{
+			name: "arrival at exit",
+			ctx:  Context{Event: "arrival", Direction: exit},
+		},
+		{
+			name: "near the door",
+			ctx:  Context{Event: "moved", Direction: direction.Inference{Direction: direction.NearDoor, Confidence: 1}},
+		},
+		{
+			name: "re-entry",
+			ctx:  Context{Event: "moved", Direction: direction.Inference{Direction: direction.ReEntry, Confidence: 1}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, ok := ruleSet.Evaluate(&test.ctx)
+			if ok != test.matches {
+				t.Fatalf("Expected match: %v, but got %v", test.matches, ok)
+			}
+			if ok && rule.Action().Type != test.action {
+				t.Errorf("Expected action %s, but got %s", test.action, rule.Action().Type)
+			}
+		})
+	}
+}
+
+func TestEvaluateFirstMatchWins(t *testing.T) {
+	ruleSet, err := NewRuleSet([]config.RuleConfig{
+		{
+			Name:       "ignore-sku",
+			Conditions: config.RuleConditions{SKUs: []string{"0123*"}, Facilities: []string{"store-1"}},
+			Action:     config.RuleActionIgnore,
+		},
+		{
+			Name:       "after-hours",
+			Conditions: config.RuleConditions{StartTime: "22:00", EndTime: "06:00", MinConfidence: 0.5},
+			Action:     config.RuleActionNotify,
+			Severity:   "NORMAL",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	night := time.Date(2019, 10, 16, 23, 30, 0, 0, time.Local)
+	day := time.Date(2019, 10, 16, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		ctx      Context
+		expected string
+	}{
+		{
+			name:     "ignored sku",
+			ctx:      Context{ProductID: "012345", FacilityID: "store-1", Confidence: 1, Time: night},
+			expected: "ignore-sku",
+		},
+		{
+			name:     "sku at another facility",
+			ctx:      Context{ProductID: "012345", FacilityID: "store-2", Confidence: 1, Time: night},
+			expected: "after-hours",
+		},
+		{
+			name: "during the day",
+			ctx:  Context{ProductID: "999999", Confidence: 1, Time: day},
+		},
+		{
+			name: "low confidence",
+			ctx:  Context{ProductID: "999999", Confidence: 0.2, Time: night},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, ok := ruleSet.Evaluate(&test.ctx)
+			if test.expected == "" {
+				if ok {
+					t.Errorf("Expected no rule to match, but %s did", rule.Name())
+				}
+				return
+			}
+			if !ok || rule.Name() != test.expected {
+				t.Errorf("Expected rule %s to match, but got %v", test.expected, rule)
+			}
+		})
+	}
+}
+
+func TestNewRuleSetErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []config.RuleConfig
+	}{
+		{
+			name:  "missing name",
+			rules: []config.RuleConfig{{Action: config.RuleActionRecord}},
+		},
+		{
+			name:  "duplicate name",
+			rules: []config.RuleConfig{{Name: "a", Action: config.RuleActionRecord}, {Name: "a", Action: config.RuleActionIgnore}},
+		},
+		{
+			name:  "unknown action",
+			rules: []config.RuleConfig{{Name: "a", Action: "explode"}},
+		},
+		{
+			name:  "unknown severity",
+			rules: []config.RuleConfig{{Name: "a", Action: config.RuleActionRecord, Severity: "LOW"}},
+		},
+		{
+			name:  "invalid time of day",
+			rules: []config.RuleConfig{{Name: "a", Action: config.RuleActionRecord, Conditions: config.RuleConditions{StartTime: "25:00", EndTime: "06:00"}}},
+		},
+		{
+			name:  "invalid confidence",
+			rules: []config.RuleConfig{{Name: "a", Action: config.RuleActionRecord, Conditions: config.RuleConditions{MinConfidence: 2}}},
+		},
+		{
+			name:  "unknown direction",
+			rules: []config.RuleConfig{{Name: "a", Action: config.RuleActionRecord, Conditions: config.RuleConditions{Directions: []string{"sideways"}}}},
+		},
+		{
+			name:  "invalid direction confidence",
+			rules: []config.RuleConfig{{Name: "a", Action: config.RuleActionRecord, Conditions: config.RuleConditions{MinDirectionConfidence: -1}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewRuleSet(test.rules); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestExpressionRule(t *testing.T) {
+	config.AppConfig.Watchlists = map[string][]string{"watchlist": {"012345"}}
+	defer func() { config.AppConfig.Watchlists = nil }()
+
+	rule, err := NewRule(config.RuleConfig{
+		Name:       "watchlist-from-fitting-room",
+		Conditions: config.RuleConditions{Expression: `tag.product_id in watchlist && from.personality == "FITTING_ROOM"`},
+		Action:     config.RuleActionRecord,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fittingRoom := rspWithPersonality("RSP-150002", sensor.FittingRoom)
+	tests := []struct {
+		name    string
+		ctx     Context
+		matches bool
+	}{
+		{
+			name:    "watched sku from fitting room",
+			ctx:     Context{Tag: map[string]string{"product_id": "012345"}, From: fittingRoom},
+			matches: true,
+		},
+		{
+			name: "watched sku from unknown sensor",
+			ctx:  Context{Tag: map[string]string{"product_id": "012345"}},
+		},
+		{
+			name: "other sku",
+			ctx:  Context{Tag: map[string]string{"product_id": "999999"}, From: fittingRoom},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if matches := rule.Matches(&test.ctx); matches != test.matches {
+				t.Errorf("Expected match: %v, but got %v", test.matches, matches)
+			}
+		})
+	}
+
+	if _, err := NewRule(config.RuleConfig{
+		Name:       "typo",
+		Conditions: config.RuleConditions{Expression: `tag.product_id in watchlists`},
+		Action:     config.RuleActionRecord,
+	}); err == nil {
+		t.Error("Expected an unknown variable to fail to compile")
+	}
+}
+
+func TestSGTINRule(t *testing.T) {
+	rule, err := NewRule(config.RuleConfig{
+		Name: "high-value-serials",
+		Conditions: config.RuleConditions{
+			GTINs:           []string{"80614141000000-80614141999999", "00012345*"},
+			CompanyPrefixes: []string{"0614141"},
+			Serials:         []string{"1000-9999"},
+		},
+		Action: config.RuleActionRecord,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		sgtin   *sgtin.SGTIN
+		matches bool
+	}{
+		{
+			name:    "in range",
+			sgtin:   &sgtin.SGTIN{CompanyPrefix: "0614141", GTIN: "80614141123458", Serial: "6789"},
+			matches: true,
+		},
+		{
+			name:  "serial out of range",
+			sgtin: &sgtin.SGTIN{CompanyPrefix: "0614141", GTIN: "80614141123458", Serial: "10000"},
+		},
+		{
+			name:  "alphanumeric serial",
+			sgtin: &sgtin.SGTIN{CompanyPrefix: "0614141", GTIN: "80614141123458", Serial: "32a/b"},
+		},
+		{
+			name:  "other company prefix",
+			sgtin: &sgtin.SGTIN{CompanyPrefix: "0012345", GTIN: "00012345678905", Serial: "6789"},
+		},
+		{
+			name: "not an sgtin",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if matches := rule.Matches(&Context{SGTIN: test.sgtin}); matches != test.matches {
+				t.Errorf("Expected match: %v, but got %v", test.matches, matches)
+			}
+		})
+	}
+
+	if _, err := NewRule(config.RuleConfig{
+		Name:       "backwards",
+		Conditions: config.RuleConditions{Serials: []string{"9999-1000"}},
+		Action:     config.RuleActionRecord,
+	}); err == nil {
+		t.Error("Expected a backwards range to fail to compile")
+	}
+}
@@ -0,0 +1,154 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package rules
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+const (
+	// ActionNone is counted when no rule matches a tag
+	ActionNone = "none"
+	// how many of the most recent disagreements are kept
+	maxDisagreements = 100
+)
+
+var (
+	shadow          RuleSet
+	comparison      = newComparison(0)
+	comparisonMutex sync.Mutex
+)
+
+// Verdict is the rule a rule set matched for a tag, and the action it would take
+type Verdict struct {
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+}
+
+// Disagreement is a tag the active and candidate rule sets would have treated differently
+type Disagreement struct {
+	EPC       string  `json:"epc"`
+	ProductID string  `json:"product_id"`
+	Timestamp int64   `json:"timestamp"`
+	Active    Verdict `json:"active"`
+	Candidate Verdict `json:"candidate"`
+}
+
+// Comparison counts how often the active and candidate (shadow) rule sets took each action on the same tags
+type Comparison struct {
+	// Time counting started in milliseconds epoch
+	Since  int64 `json:"since"`
+	DryRun bool  `json:"dry_run"`
+	// Number of tags evaluated by the rules
+	Evaluated int            `json:"evaluated"`
+	Active    map[string]int `json:"active"`
+	// Only counted when a shadow rule set is configured
+	Candidate     map[string]int `json:"candidate,omitempty"`
+	Disagreements int            `json:"disagreements"`
+	// The most recent disagreements, oldest first
+	Recent []Disagreement `json:"recent_disagreements"`
+}
+
+func newComparison(since int64) *Comparison {
+	return &Comparison{
+		Since:  since,
+		Active: make(map[string]int),
+		Recent: []Disagreement{},
+	}
+}
+
+// VerdictOf returns the verdict of an evaluated rule set
+func VerdictOf(rule Rule, matched bool) Verdict {
+	if !matched {
+		return Verdict{Action: ActionNone}
+	}
+	return Verdict{Rule: rule.Name(), Action: rule.Action().Type}
+}
+
+// SetupShadowRules compiles the configured candidate rules, and starts a new comparison
+func SetupShadowRules(now int64) error {
+	var ruleSet RuleSet
+	if len(config.AppConfig.ShadowRules) > 0 {
+		var err error
+		if ruleSet, err = NewRuleSet(config.AppConfig.ShadowRules); err != nil {
+			return err
+		}
+		logrus.Debugf("Configured shadow rules: %+v", config.AppConfig.ShadowRules)
+	}
+
+	comparisonMutex.Lock()
+	shadow = ruleSet
+	comparison = newComparison(now)
+	if shadow != nil {
+		comparison.Candidate = make(map[string]int)
+	}
+	comparisonMutex.Unlock()
+	return nil
+}
+
+// Compare counts the verdict of the active rule set for a tag, and evaluates the candidate rule set
+// against the same tag. The candidate rule set never affects what happens to the tag.
+func Compare(ctx *Context, active Verdict, now int64) {
+	comparisonMutex.Lock()
+	defer comparisonMutex.Unlock()
+
+	comparison.Evaluated++
+	comparison.Active[active.Action]++
+	metrics.GetOrRegisterCounter("loss-prevention-service.Rules.Active."+active.Action, nil).Inc(1)
+
+	if shadow == nil {
+		return
+	}
+
+	candidate := VerdictOf(shadow.Evaluate(ctx))
+	comparison.Candidate[candidate.Action]++
+	metrics.GetOrRegisterCounter("loss-prevention-service.Rules.Candidate."+candidate.Action, nil).Inc(1)
+
+	if candidate == active {
+		return
+	}
+
+	logrus.Debugf("shadow rules disagree on epc %s (sku: %s): active: %+v, candidate: %+v", ctx.EPC, ctx.ProductID, active, candidate)
+	comparison.Disagreements++
+	comparison.Recent = append(comparison.Recent, Disagreement{
+		EPC:       ctx.EPC,
+		ProductID: ctx.ProductID,
+		Timestamp: now,
+		Active:    active,
+		Candidate: candidate,
+	})
+	if len(comparison.Recent) > maxDisagreements {
+		comparison.Recent = comparison.Recent[len(comparison.Recent)-maxDisagreements:]
+	}
+}
+
+// GetComparison returns a copy of the current comparison
+func GetComparison() Comparison {
+	comparisonMutex.Lock()
+	defer comparisonMutex.Unlock()
+
+	result := *comparison
+	result.DryRun = config.AppConfig.DryRun
+	result.Active = copyCounts(comparison.Active)
+	result.Candidate = copyCounts(comparison.Candidate)
+	result.Recent = append([]Disagreement{}, comparison.Recent...)
+	return result
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	if counts == nil {
+		return nil
+	}
+	result := make(map[string]int, len(counts))
+	for action, count := range counts {
+		result[action] = count
+	}
+	return result
+}
@@ -0,0 +1,61 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package rules
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/direction"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/sensor"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	config.AppConfig.ShadowRules = []config.RuleConfig{
+		{
+			Name:       "any-exit",
+			Conditions: config.RuleConditions{Events: []string{"moved"}, ToPersonalities: []string{"EXIT"}},
+			Action:     config.RuleActionNotify,
+		},
+	}
+	defer func() { config.AppConfig.ShadowRules = nil }()
+
+	if err := SetupShadowRules(1000); err != nil {
+		t.Fatal(err)
+	}
+	active, err := NewRuleSet(config.DefaultRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exit := rspWithPersonality("RSP-150000", sensor.Exit)
+	floor := rspWithPersonality("RSP-150001", sensor.NoPersonality)
+	contexts := []Context{
+		{EPC: "A", Event: "moved", From: floor, To: exit, Direction: direction.Inference{Direction: direction.Exit, Confidence: 1}},
+		{EPC: "B", Event: "moved", From: exit, To: exit, Direction: direction.Inference{Direction: direction.NearDoor, Confidence: 1}},
+		{EPC: "C", Event: "arrival", From: floor, To: floor, Direction: direction.Inference{Direction: direction.Inside, Confidence: 1}},
+	}
+	for i := range contexts {
+		Compare(&contexts[i], VerdictOf(active.Evaluate(&contexts[i])), 2000)
+	}
+
+	comparison := GetComparison()
+	if comparison.Evaluated != 3 {
+		t.Errorf("Expected 3 evaluated tags, but got %d", comparison.Evaluated)
+	}
+	if comparison.Active[config.RuleActionRecord] != 1 || comparison.Active[ActionNone] != 2 {
+		t.Errorf("Unexpected active counts: %v", comparison.Active)
+	}
+	if comparison.Candidate[config.RuleActionNotify] != 2 || comparison.Candidate[ActionNone] != 1 {
+		t.Errorf("Unexpected candidate counts: %v", comparison.Candidate)
+	}
+	if comparison.Disagreements != 2 || len(comparison.Recent) != 2 {
+		t.Fatalf("Expected 2 disagreements, but got %d", comparison.Disagreements)
+	}
+	if comparison.Recent[1].EPC != "B" || comparison.Recent[1].Active.Action != ActionNone || comparison.Recent[1].Candidate.Rule != "any-exit" {
+		t.Errorf("Unexpected disagreement: %+v", comparison.Recent[1])
+	}
+}
@@ -0,0 +1,57 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package webserver
+
+import (
+	"context"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/decision"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/lossprevention"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/jsonrpc"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/web"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultDecisionLimit = 100
+)
+
+// ListDecisions returns the most recent trigger decisions, newest first. The optional `epc` query parameter
+// limits the results to a single EPC, and `limit` to a number of decisions (default 100).
+func (handler *Handler) ListDecisions(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+	query := request.URL.Query()
+
+	limit := defaultDecisionLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			return errors.Wrapf(web.ErrInvalidInput, "limit must be a number greater than 0, but got %s", value)
+		}
+	}
+
+	web.Respond(ctx, writer, decision.Query(query.Get("epc"), limit), http.StatusOK)
+	return nil
+}
+
+// Explain evaluates a raw inventory_event payload with the current sensors, rules and configuration, and
+// returns the decision made for every tag in it. Nothing is recorded or notified.
+func (handler *Handler) Explain(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+
+	payload := new(lossprevention.DataPayload)
+	if err := jsonrpc.DecodeWithoutMetrics(string(body), payload); err != nil {
+		return errors.Wrap(web.ErrInvalidInput, err.Error())
+	}
+
+	web.Respond(ctx, writer, lossprevention.Explain(payload), http.StatusOK)
+	return nil
+}
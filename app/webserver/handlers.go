@@ -11,8 +11,12 @@ import (
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/catalog"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/incident"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/lossprevention"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -51,44 +55,90 @@ func (handler *Handler) ListRecordings(ctx context.Context, writer http.Response
 	}
 
 	resp := NewRecordingsResponse(len(folders))
-	for i, folder := range folders {
-		tokens := strings.Split(folder.Name(), "_")
-		if len(tokens) != 3 {
-			logrus.Warnf("folder: %s does not appear to match expected format! skipping.", folder.Name())
-			continue
-		}
-		ts, err := strconv.ParseInt(tokens[0], 10, 64)
-		if err != nil {
-			logrus.Warnf("unable to parse timestamp from folder name: %v", err)
-			continue
-		}
-		files, err := ioutil.ReadDir(filepath.Join(baseFolder, folder.Name()))
-		if err != nil {
-			logrus.Warnf("unable to read recording directory %s: %v", folder.Name(), err)
+	for _, folder := range folders {
+		var info RecordingInfo
+		metadata, err := lossprevention.ReadMetadata(filepath.Join(baseFolder, folder.Name()))
+		switch {
+		case err == nil:
+			info = newRecordingInfo(folder.Name(), metadata)
+		case os.IsNotExist(err):
+			// recordings made by older versions do not have any metadata
+			if info, err = legacyRecordingInfo(folder.Name()); err != nil {
+				logrus.Warnf("folder %s has no recording metadata, and %v. skipping.", folder.Name(), err)
+				continue
+			}
+		default:
+			logrus.Warnf("unable to read recording metadata for %s, skipping: %v", folder.Name(), err)
 			continue
 		}
 
-		info := RecordingInfo{
-			FolderName: folder.Name(),
-			Timestamp:  ts,
-			ProductId:  tokens[1],
-			EPC:        tokens[2],
-			Video:      "video" + config.AppConfig.VideoOutputExtension,
-			Thumb:      "thumb.jpg",
-		}
-		for _, file := range files {
-			if strings.HasSuffix(file.Name(), ".jpg") && file.Name() != "thumb.jpg" && !strings.HasPrefix(file.Name(), "frame.") {
-				info.Detections = append(info.Detections, file.Name())
-			}
+		if product, ok := catalog.Lookup(info.ProductId); ok {
+			info.ProductName = product.Name
 		}
-
-		resp.Recordings[i] = info
+		resp.Recordings = append(resp.Recordings, info)
 	}
 	logrus.Tracef("%+v", resp)
 	web.Respond(ctx, writer, resp, http.StatusOK)
 	return nil
 }
 
+// newRecordingInfo describes a recording from its metadata
+func newRecordingInfo(folderName string, metadata *lossprevention.RecordingMetadata) RecordingInfo {
+	inc := metadata.Incident
+	info := RecordingInfo{
+		FolderName: folderName,
+		IncidentID: inc.ID,
+		Camera:     inc.Camera,
+		Timestamp:  inc.Timestamp,
+		Video:      metadata.Recording.Video,
+		Thumb:      metadata.Recording.Thumb,
+		Detections: metadata.Recording.Crops(),
+		Tags:       inc.Tags,
+	}
+	if len(inc.Tags) > 0 {
+		info.EPC = inc.Tags[0].EPC
+		info.ProductId = inc.Tags[0].ProductID
+	}
+	return info
+}
+
+// legacyRecordingInfo describes a recording made before recordings had metadata, from its folder name
+// and the images in its folder. It has no incident, and its only tag is the one in the folder name.
+func legacyRecordingInfo(folderName string) (RecordingInfo, error) {
+	// folder name format is timestamp_sku_epc[_camera]
+	tokens := strings.Split(folderName, "_")
+	if len(tokens) != 3 && len(tokens) != 4 {
+		return RecordingInfo{}, fmt.Errorf("its name does not match the expected format")
+	}
+	ts, err := strconv.ParseInt(tokens[0], 10, 64)
+	if err != nil {
+		return RecordingInfo{}, fmt.Errorf("unable to parse timestamp from its name: %v", err)
+	}
+	files, err := ioutil.ReadDir(filepath.Join(baseFolder, folderName))
+	if err != nil {
+		return RecordingInfo{}, fmt.Errorf("unable to read it: %v", err)
+	}
+
+	info := RecordingInfo{
+		FolderName: folderName,
+		Timestamp:  ts,
+		ProductId:  tokens[1],
+		EPC:        tokens[2],
+		Video:      "video" + config.AppConfig.VideoOutputExtension,
+		Thumb:      "thumb.jpg",
+		Tags:       []incident.Tag{{EPC: tokens[2], ProductID: tokens[1]}},
+	}
+	if len(tokens) == 4 {
+		info.Camera = tokens[3]
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".jpg") && file.Name() != info.Thumb && !strings.HasPrefix(file.Name(), "frame.") {
+			info.Detections = append(info.Detections, file.Name())
+		}
+	}
+	return info, nil
+}
+
 func (handler *Handler) DeleteRecording(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
 	vars := mux.Vars(request)
 	folder, ok := vars["foldername"]
@@ -97,7 +147,7 @@ func (handler *Handler) DeleteRecording(ctx context.Context, writer http.Respons
 		return fmt.Errorf("bad request")
 	}
 
-	if err := os.RemoveAll(path.Join(baseFolder, folder)); err != nil {
+	if err := deleteRecording(folder); err != nil {
 		logrus.Error(err)
 		web.Respond(ctx, writer, "Internal Error", http.StatusInternalServerError)
 		return err
@@ -116,7 +166,7 @@ func (handler *Handler) DeleteAllRecordings(ctx context.Context, writer http.Res
 	}
 
 	for _, folder := range folders {
-		if err := os.RemoveAll(path.Join(baseFolder, folder.Name())); err != nil {
+		if err := deleteRecording(folder.Name()); err != nil {
 			logrus.Error(err)
 			web.Respond(ctx, writer, "Internal Error", http.StatusInternalServerError)
 			return err
@@ -127,6 +177,39 @@ func (handler *Handler) DeleteAllRecordings(ctx context.Context, writer http.Res
 	return nil
 }
 
+// deleteRecording deletes a recording folder, and removes the recording from the incident it belongs to
+func deleteRecording(folderName string) error {
+	metadata, metadataErr := lossprevention.ReadMetadata(filepath.Join(baseFolder, folderName))
+
+	if err := os.RemoveAll(path.Join(baseFolder, folderName)); err != nil {
+		return err
+	}
+
+	// recordings made by older versions do not belong to any incident
+	if metadataErr != nil {
+		return nil
+	}
+	_, err := incident.Update(metadata.Incident.ID, helper.UnixMilliNow(), func(inc *incident.Incident) error {
+		recordings := make([]string, 0, len(inc.Recordings))
+		for _, recording := range inc.Recordings {
+			if recording != folderName {
+				recordings = append(recordings, recording)
+			}
+		}
+		inc.Recordings = recordings
+		// the detections are the crops of the recording
+		if len(recordings) == 0 {
+			inc.Detections = nil
+		}
+		return nil
+	})
+	if err != nil {
+		// the recording is gone either way
+		logrus.Warnf("unable to remove recording %s from incident %s: %v", folderName, metadata.Incident.ID, err)
+	}
+	return nil
+}
+
 func (handler *Handler) Options(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
 	web.Respond(ctx, writer, nil, http.StatusOK)
 	return nil
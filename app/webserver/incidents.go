@@ -0,0 +1,80 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/incident"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// StatusUpdate is the request body used to move an incident to a new review status
+type StatusUpdate struct {
+	Status   incident.Status `json:"status"`
+	Reviewer string          `json:"reviewer"`
+	Notes    string          `json:"notes"`
+}
+
+// ListIncidents returns every stored incident, newest first. The optional `status` query
+// parameter limits the results to incidents with that status.
+func (handler *Handler) ListIncidents(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+	status := incident.Status(request.URL.Query().Get("status"))
+	if status != "" && !status.IsValid() {
+		return errors.Wrapf(web.ErrInvalidInput, "unknown status %s", status)
+	}
+
+	incidents, err := incident.List(status)
+	if err != nil {
+		return err
+	}
+
+	web.Respond(ctx, writer, incidents, http.StatusOK)
+	return nil
+}
+
+// GetIncident returns a single incident
+func (handler *Handler) GetIncident(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+	inc, err := incident.Find(mux.Vars(request)["id"])
+	if err != nil {
+		return incidentError(err)
+	}
+
+	web.Respond(ctx, writer, inc, http.StatusOK)
+	return nil
+}
+
+// UpdateIncidentStatus moves an incident to a new review status along with the reviewer's notes
+func (handler *Handler) UpdateIncidentStatus(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+	var update StatusUpdate
+	if err := json.NewDecoder(request.Body).Decode(&update); err != nil {
+		return errors.Wrap(web.ErrInvalidInput, err.Error())
+	}
+
+	inc, err := incident.Transition(mux.Vars(request)["id"], update.Status, update.Reviewer, update.Notes, helper.UnixMilliNow())
+	if err != nil {
+		return incidentError(err)
+	}
+
+	web.Respond(ctx, writer, inc, http.StatusOK)
+	return nil
+}
+
+// incidentError maps incident store errors to their web equivalents
+func incidentError(err error) error {
+	switch errors.Cause(err) {
+	case incident.ErrNotFound:
+		return errors.Wrap(web.ErrNotFound, err.Error())
+	case incident.ErrInvalidStatus, incident.ErrInvalidTransition:
+		return errors.Wrap(web.ErrValidation, err.Error())
+	}
+	return err
+}
@@ -6,7 +6,10 @@
 
 package webserver
 
-import "github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/incident"
+)
 
 type RecordingsResponse struct {
 	BaseUrl     string          `json:"base_url"`
@@ -18,16 +21,24 @@ func NewRecordingsResponse(count int) RecordingsResponse {
 	return RecordingsResponse{
 		BaseUrl:     config.AppConfig.VideoUrlBase,
 		ThumbHeight: config.AppConfig.ThumbnailHeight,
-		Recordings:  make([]RecordingInfo, count),
+		Recordings:  make([]RecordingInfo, 0, count),
 	}
 }
 
 type RecordingInfo struct {
-	FolderName string   `json:"folder_name"`
-	EPC        string   `json:"epc"`
-	ProductId  string   `json:"product_id"`
-	Timestamp  int64    `json:"timestamp"`
-	Video      string   `json:"video"`
-	Thumb      string   `json:"thumb"`
+	FolderName string `json:"folder_name"`
+	IncidentID string `json:"incident_id"`
+	EPC        string `json:"epc"`
+	ProductId  string `json:"product_id"`
+	Camera     string `json:"camera,omitempty"`
+	Timestamp  int64  `json:"timestamp"`
+	Video      string `json:"video"`
+	Thumb      string `json:"thumb"`
+	// Filenames of the best crops of every tracked object, named <label>.<track id>.jpg for the best one of each
+	// track and <label>.<track id>.<rank>.jpg for the others, such as face.3.jpg and face.3.1.jpg
 	Detections []string `json:"detections"`
+	// Name of the product from the catalog, if it is in the catalog
+	ProductName string `json:"product_name,omitempty"`
+	// every tag which triggered this recording
+	Tags []incident.Tag `json:"tags"`
 }
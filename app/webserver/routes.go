@@ -83,6 +83,78 @@ func NewRouter() *mux.Router {
 			"/recordings/{foldername}",
 			handler.Options,
 		},
+		{
+			"PostSale",
+			"POST",
+			"/sales",
+			handler.PostSale,
+		},
+		{
+			"OptionsPostSale",
+			"OPTIONS",
+			"/sales",
+			handler.Options,
+		},
+		{
+			"ListIncidents",
+			"GET",
+			"/incidents",
+			handler.ListIncidents,
+		},
+		{
+			"GetIncident",
+			"GET",
+			"/incidents/{id}",
+			handler.GetIncident,
+		},
+		{
+			"UpdateIncidentStatus",
+			"PUT",
+			"/incidents/{id}/status",
+			handler.UpdateIncidentStatus,
+		},
+		{
+			"OptionsUpdateIncidentStatus",
+			"OPTIONS",
+			"/incidents/{id}/status",
+			handler.Options,
+		},
+		{
+			"TestExpression",
+			"POST",
+			"/rules/expression",
+			handler.TestExpression,
+		},
+		{
+			"OptionsTestExpression",
+			"OPTIONS",
+			"/rules/expression",
+			handler.Options,
+		},
+		{
+			"GetShadowComparison",
+			"GET",
+			"/rules/shadow",
+			handler.GetShadowComparison,
+		},
+		{
+			"ListDecisions",
+			"GET",
+			"/decisions",
+			handler.ListDecisions,
+		},
+		{
+			"Explain",
+			"POST",
+			"/explain",
+			handler.Explain,
+		},
+		{
+			"OptionsExplain",
+			"OPTIONS",
+			"/explain",
+			handler.Options,
+		},
 	}
 
 	router := mux.NewRouter().StrictSlash(true)
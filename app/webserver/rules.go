@@ -0,0 +1,70 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/lossprevention"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/rules"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/jsonrpc"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/web"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+)
+
+// ExpressionTest is the request body used to test a rule expression against a sample inventory_event payload
+type ExpressionTest struct {
+	Expression string          `json:"expression"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Validate implements the jsonrpc.Message interface
+func (test *ExpressionTest) Validate() error {
+	if test.Expression == "" {
+		return errors.New("missing expression field")
+	}
+	if len(test.Payload) == 0 {
+		return errors.New("missing payload field")
+	}
+	return nil
+}
+
+// TestExpression compiles a rule expression and evaluates it against every tag in the sample payload.
+// Nothing is recorded or notified.
+func (handler *Handler) TestExpression(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+
+	test := new(ExpressionTest)
+	if err := jsonrpc.DecodeWithoutMetrics(string(body), test); err != nil {
+		return errors.Wrap(web.ErrInvalidInput, err.Error())
+	}
+
+	payload := new(lossprevention.DataPayload)
+	if err := jsonrpc.DecodeWithoutMetrics(string(test.Payload), payload); err != nil {
+		return errors.Wrap(web.ErrInvalidInput, err.Error())
+	}
+
+	program, err := config.CompileExpression(test.Expression)
+	if err != nil {
+		return errors.Wrap(web.ErrValidation, err.Error())
+	}
+
+	web.Respond(ctx, writer, lossprevention.EvaluateExpression(program, payload), http.StatusOK)
+	return nil
+}
+
+// GetShadowComparison returns how often the active and candidate (shadow) rule sets would have taken each action
+func (handler *Handler) GetShadowComparison(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+	web.Respond(ctx, writer, rules.GetComparison(), http.StatusOK)
+	return nil
+}
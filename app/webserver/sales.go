@@ -0,0 +1,38 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package webserver
+
+import (
+	"context"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/pos"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/jsonrpc"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+)
+
+// PostSale ingests a sale transaction from the point-of-sale system so that the sold EPCs
+// do not trigger when they leave the store
+func (handler *Handler) PostSale(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+
+	transaction := new(pos.SaleTransaction)
+	if err := jsonrpc.Decode(string(body), transaction, nil); err != nil {
+		return errors.Wrap(web.ErrInvalidInput, err.Error())
+	}
+
+	pos.RecordTransaction(transaction, helper.UnixMilliNow(), int64(config.AppConfig.SaleReconciliationWindow)*1000)
+
+	web.Respond(ctx, writer, nil, http.StatusCreated)
+	return nil
+}
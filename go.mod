@@ -10,6 +10,7 @@ require (
 	github.com/pborman/uuid v1.2.0
 	github.com/pkg/errors v0.8.1
 	github.com/sirupsen/logrus v1.4.2
+	go.etcd.io/bbolt v1.3.3
 	gocv.io/x/gocv v0.21.0
 	golang.org/x/sync v0.0.0-20181221193216-37e7f081c4d4
 )
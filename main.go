@@ -7,12 +7,20 @@
 package main
 
 import (
+	"flag"
 	"github.com/edgexfoundry/app-functions-sdk-go/pkg/transforms"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/catalog"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/decision"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/filterlist"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/incident"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/lossprevention"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/notification"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/pos"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/rules"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/webserver"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/camera"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/clock"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/jsonrpc"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/sensor"
 	"os"
@@ -27,12 +35,14 @@ import (
 	"github.com/edgexfoundry/app-functions-sdk-go/appsdk"
 	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
 	reporter "github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics-influxdb"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
 )
 
 const (
 	serviceKey               = "loss-prevention-service"
 	inventoryEvent           = "inventory_event"
 	sensorConfigNotification = "sensor_config_notification"
+	posSale                  = "pos_sale"
 )
 
 var (
@@ -40,10 +50,15 @@ var (
 	valueDescriptors = []string{
 		inventoryEvent,
 		sensorConfigNotification,
+		posSale,
 	}
 )
 
 func main() {
+	replayFile := flag.String("replay", "", "replay a file of captured readings without the cameras, print a report of the incidents they generate and exit")
+	replaySpeed := flag.Float64("replaySpeed", 0, "how many times faster than their original timing readings are replayed, 0 replays them as fast as possible")
+	flag.Parse()
+
 	mConfigurationError := metrics.GetOrRegisterGauge("loss-prevention-service.Main.ConfigurationError", nil)
 
 	// Load config variables
@@ -60,22 +75,58 @@ func main() {
 		"Action": "Start",
 	}).Info("Starting Loss Prevention Service...")
 
+	err = rules.SetupRules()
+	fatalErrorHandler("unable to load rules", err, &mConfigurationError)
+	err = rules.SetupShadowRules(helper.UnixMilliNow())
+	fatalErrorHandler("unable to load shadow rules", err, &mConfigurationError)
+	err = catalog.Setup()
+	fatalErrorHandler("unable to load product catalog", err, &mConfigurationError)
+	err = filterlist.Setup()
+	fatalErrorHandler("unable to load filter lists", err, &mConfigurationError)
+	decision.Setup(config.AppConfig.DecisionLogSize)
+
+	if *replayFile != "" {
+		camera.SetupCameras()
+		if err := replay(*replayFile, *replaySpeed, os.Stdout); err != nil {
+			logrus.Errorf("unable to replay %s: %v", *replayFile, err)
+			os.Exit(-1)
+		}
+		return
+	}
+
+	err = incident.Open(config.AppConfig.IncidentDatabaseFile)
+	fatalErrorHandler("unable to open incident database", err, &mConfigurationError)
+	defer incident.Close()
+
 	go registerSubscribers()
 
+	go catalog.Refresh(time.Duration(config.AppConfig.CatalogRefreshInterval) * time.Second)
+
+	go filterlist.Watch(time.Duration(config.AppConfig.FilterListsPollInterval) * time.Second)
+
+	stopWatchingFittingRooms := lossprevention.WatchFittingRooms()
+	defer stopWatchingFittingRooms()
+
 	go sensor.QueryBasicInfoAllSensors()
 
 	// Connect to EdgeX zeroMQ bus
 	go receiveZMQEvents()
 
-	if _, err := camera.SanityCheck(); err != nil {
-		logrus.Errorf("error running camera sanity check: %v", err)
-		logrus.Error("service will now exit...")
-		os.Exit(-1)
+	if config.AppConfig.DryRun {
+		// dry run mode never touches the cameras
+		logrus.Info("Running in dry run mode, nothing will be recorded or notified")
+		camera.SetupCameras()
 	} else {
-		logrus.Info("Camera sanity check was successful")
+		if _, err := camera.SanityCheck(); err != nil {
+			logrus.Errorf("error running camera sanity check: %v", err)
+			logrus.Error("service will now exit...")
+			os.Exit(-1)
+		} else {
+			logrus.Info("Camera sanity check was successful")
+		}
 	}
 
-	go camera.RecordVideoToDisk(config.AppConfig.VideoDevice, 10, "/tmp/testing", true)
+	defer camera.CloseDetectors()
 
 	webserver.StartWebServer(config.AppConfig.Port)
 
@@ -152,7 +203,7 @@ func processEvents(edgexcontext *appcontext.Context, params ...interface{}) (boo
 				return false, err
 			}
 
-			if err := lossprevention.HandleDataPayload(edgexcontext, payload); err != nil {
+			if err := lossprevention.HandleSegment(edgexcontext, payload); err != nil {
 				return false, err
 			}
 
@@ -167,6 +218,16 @@ func processEvents(edgexcontext *appcontext.Context, params ...interface{}) (boo
 			rsp := sensor.NewRSPFromConfigNotification(notif)
 			sensor.UpdateRSP(rsp)
 
+		case posSale:
+			logrus.Debugf("Received pos sale: %s", strings.ReplaceAll(strings.ReplaceAll(reading.Value, "\\", ""), "\"", "'"))
+
+			transaction := new(pos.SaleTransaction)
+			if err := jsonrpc.Decode(reading.Value, transaction, nil); err != nil {
+				return false, err
+			}
+
+			pos.RecordTransaction(transaction, clock.Now(), int64(config.AppConfig.SaleReconciliationWindow)*1000)
+
 		default:
 			logrus.Warnf("received unsupported event: %s", reading.Name)
 		}
@@ -0,0 +1,150 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package camera
+
+import (
+	"fmt"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/tracker"
+	"github.com/sirupsen/logrus"
+	"gocv.io/x/gocv"
+	"image"
+	"math"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// variance of the Laplacian at which a crop counts as half sharp. Crops below roughly this value
+	// are noticeably blurry.
+	sharpnessScale = 100.0
+)
+
+// shot is a candidate crop of a tracked object
+type shot struct {
+	crop  gocv.Mat
+	score float64
+	// index of the detection in the recording metadata
+	detection int
+}
+
+// bestShots keeps the highest scoring crops of each tracked object, so that only the clearest images of
+// each object are written instead of whichever crop happened to come first
+type bestShots struct {
+	// how many crops are kept per track
+	count int
+
+	labels  map[int]string
+	byTrack map[int][]*shot
+	// writes a crop to a file, returning false if it could not be written
+	save func(filename string, crop gocv.Mat) bool
+}
+
+func newBestShots(count int) *bestShots {
+	return &bestShots{
+		count:   count,
+		labels:  make(map[int]string),
+		byTrack: make(map[int][]*shot),
+		save:    gocv.IMWrite,
+	}
+}
+
+// cropFilename is the name of the crop of a track, by its rank among the best shots of the track. The best
+// shot is named <label>.<track id>.jpg, such as face.3.jpg, and the others face.3.1.jpg, face.3.2.jpg and so on.
+func cropFilename(label string, trackID int, rank int) string {
+	if rank == 0 {
+		return fmt.Sprintf("%s.%d.jpg", label, trackID)
+	}
+	return fmt.Sprintf("%s.%d.%d.jpg", label, trackID, rank)
+}
+
+// shotScore scores a crop by its sharpness, size and detector confidence. Sharpness has diminishing
+// returns, so that a large, confident, slightly soft crop beats a tiny sharp one.
+func shotScore(sharpness float64, rect image.Rectangle, confidence float64) float64 {
+	return sharpness / (sharpness + sharpnessScale) * math.Sqrt(float64(rect.Dx()*rect.Dy())) * confidence
+}
+
+// sharpness returns the variance of the Laplacian of a crop, which is low for blurry images
+func sharpness(crop gocv.Mat) float64 {
+	gray := gocv.NewMat()
+	defer safeClose(&gray)
+	laplacian := gocv.NewMat()
+	defer safeClose(&laplacian)
+	mean := gocv.NewMat()
+	defer safeClose(&mean)
+	stdDev := gocv.NewMat()
+	defer safeClose(&stdDev)
+
+	gocv.CvtColor(crop, &gray, gocv.ColorBGRToGray)
+	gocv.Laplacian(gray, &laplacian, gocv.MatTypeCV64F, 1, 1, 0, gocv.BorderDefault)
+	gocv.MeanStdDev(laplacian, &mean, &stdDev)
+	return math.Pow(stdDev.GetDoubleAt(0, 0), 2)
+}
+
+// offer scores the crop of a detection, and keeps it if it is one of the best of its track so far.
+// index is the index of the detection in the recording metadata.
+func (shots *bestShots) offer(track *tracker.Track, frame gocv.Mat, detection Detection, index int) {
+	region := frame.Region(detection.Rect)
+	defer safeClose(&region)
+
+	score := shotScore(sharpness(region), detection.Rect, detection.Confidence)
+	if !shots.accepts(track.ID, score) {
+		return
+	}
+	if evicted := shots.keep(track, &shot{crop: region.Clone(), score: score, detection: index}); evicted != nil {
+		safeClose(&evicted.crop)
+	}
+}
+
+// accepts returns whether a crop with the score would be one of the best of the track
+func (shots *bestShots) accepts(trackID int, score float64) bool {
+	kept := shots.byTrack[trackID]
+	return len(kept) < shots.count || score > kept[len(kept)-1].score
+}
+
+// keep adds an accepted shot to its track, best first. Once the track has count shots, the worst shot is
+// evicted and returned, and the caller closes its crop.
+func (shots *bestShots) keep(track *tracker.Track, candidate *shot) (evicted *shot) {
+	kept := shots.byTrack[track.ID]
+	if len(kept) >= shots.count {
+		evicted = kept[len(kept)-1]
+		kept = kept[:len(kept)-1]
+	}
+
+	kept = append(kept, candidate)
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].score > kept[j].score
+	})
+	shots.byTrack[track.ID] = kept
+	shots.labels[track.ID] = track.Label
+	return evicted
+}
+
+// write writes the kept crops of every track to the folder, named by cropFilename, and sets the crop
+// filename of the detections they were taken from
+func (shots *bestShots) write(folder string, detections []DetectionMetadata) {
+	for id, kept := range shots.byTrack {
+		for rank, shot := range kept {
+			filename := cropFilename(shots.labels[id], id, rank)
+			logrus.Debugf("writing best shot: %s (score %.1f)", filename, shot.score)
+			if !shots.save(filepath.Join(folder, filename), shot.crop) {
+				logrus.Errorf("unable to write best shot: %s", filename)
+				continue
+			}
+			detections[shot.detection].Crop = filename
+		}
+	}
+}
+
+func (shots *bestShots) Close() error {
+	for _, kept := range shots.byTrack {
+		for _, shot := range kept {
+			safeClose(&shot.crop)
+		}
+	}
+	shots.byTrack = make(map[int][]*shot)
+	return nil
+}
@@ -0,0 +1,142 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package camera
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/tracker"
+	"gocv.io/x/gocv"
+	"image"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestShotScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		sharpness  float64
+		rect       image.Rectangle
+		confidence float64
+		expected   float64
+	}{
+		{"blank", 0, image.Rect(0, 0, 100, 100), 1, 0},
+		{"half sharp", sharpnessScale, image.Rect(0, 0, 100, 100), 1, 50},
+		{"half confident", sharpnessScale, image.Rect(0, 0, 100, 100), 0.5, 25},
+		{"four times the area", sharpnessScale, image.Rect(0, 0, 200, 200), 1, 100},
+		{"very sharp", 99 * sharpnessScale, image.Rect(0, 0, 100, 100), 1, 99},
+	}
+	for _, test := range tests {
+		if score := shotScore(test.sharpness, test.rect, test.confidence); score != test.expected {
+			t.Errorf("%s: expected a score of %v, but got %v", test.name, test.expected, score)
+		}
+	}
+
+	// a large, confident, slightly soft crop beats a tiny sharp one
+	soft := shotScore(sharpnessScale/2, image.Rect(0, 0, 200, 200), 0.9)
+	tiny := shotScore(10*sharpnessScale, image.Rect(0, 0, 20, 20), 0.9)
+	if soft <= tiny {
+		t.Errorf("expected a large soft crop (%v) to beat a tiny sharp one (%v)", soft, tiny)
+	}
+}
+
+func TestKeepEvictsWorstShot(t *testing.T) {
+	tests := []struct {
+		name   string
+		count  int
+		scores []float64
+		// detection indexes of the kept shots, best first
+		expected []int
+		// detection indexes of the evicted shots, in the order they were evicted
+		evicted []int
+	}{
+		{"single", 1, []float64{5}, []int{0}, nil},
+		{"better replaces worst", 1, []float64{5, 8}, []int{1}, []int{0}},
+		{"worse is dropped", 1, []float64{5, 3}, []int{0}, nil},
+		{"equal is dropped", 1, []float64{5, 5}, []int{0}, nil},
+		{"ordered best first", 3, []float64{2, 9, 5}, []int{1, 2, 0}, nil},
+		{"worst of several evicted", 3, []float64{2, 9, 5, 7, 1}, []int{1, 3, 2}, []int{0}},
+		{"evicted in turn", 2, []float64{3, 6, 4, 8, 7}, []int{3, 4}, []int{0, 2, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shots := newBestShots(test.count)
+			defer shots.Close()
+			track := &tracker.Track{ID: 1, Label: "face"}
+			// a second track is kept separately
+			other := &tracker.Track{ID: 2, Label: "face"}
+
+			var evicted []int
+			for index, score := range test.scores {
+				if shots.accepts(track.ID, score) {
+					if shot := shots.keep(track, &shot{crop: gocv.NewMat(), score: score, detection: index}); shot != nil {
+						evicted = append(evicted, shot.detection)
+						safeClose(&shot.crop)
+					}
+				}
+				if shots.accepts(other.ID, 100) {
+					shots.keep(other, &shot{crop: gocv.NewMat(), score: 100, detection: -1})
+				}
+			}
+
+			var kept []int
+			for _, shot := range shots.byTrack[track.ID] {
+				kept = append(kept, shot.detection)
+			}
+			if !reflect.DeepEqual(kept, test.expected) {
+				t.Errorf("expected detections %v to be kept, but got %v", test.expected, kept)
+			}
+			if !reflect.DeepEqual(evicted, test.evicted) {
+				t.Errorf("expected detections %v to be evicted, but got %v", test.evicted, evicted)
+			}
+			if len(shots.byTrack[other.ID]) != test.count {
+				t.Errorf("expected %d shots of the other track, but got %d", test.count, len(shots.byTrack[other.ID]))
+			}
+		})
+	}
+}
+
+func TestWriteNamesCrops(t *testing.T) {
+	shots := newBestShots(3)
+	defer shots.Close()
+
+	var written []string
+	shots.save = func(filename string, crop gocv.Mat) bool {
+		written = append(written, filename)
+		// a crop which cannot be written is not set on its detection
+		return filename != filepath.Join("/recordings/incident", "person.7.jpg")
+	}
+
+	face := &tracker.Track{ID: 3, Label: "face"}
+	for index, score := range []float64{5, 9, 7} {
+		shots.keep(face, &shot{crop: gocv.NewMat(), score: score, detection: index})
+	}
+	shots.keep(&tracker.Track{ID: 7, Label: "person"}, &shot{crop: gocv.NewMat(), score: 4, detection: 3})
+
+	detections := make([]DetectionMetadata, 4)
+	shots.write("/recordings/incident", detections)
+
+	var crops []string
+	for _, detection := range detections {
+		crops = append(crops, detection.Crop)
+	}
+	if expected := []string{"face.3.2.jpg", "face.3.jpg", "face.3.1.jpg", ""}; !reflect.DeepEqual(crops, expected) {
+		t.Errorf("expected the crops of the detections to be %v, but got %v", expected, crops)
+	}
+
+	sort.Strings(written)
+	expected := []string{
+		"/recordings/incident/face.3.1.jpg",
+		"/recordings/incident/face.3.2.jpg",
+		"/recordings/incident/face.3.jpg",
+		"/recordings/incident/person.7.jpg",
+	}
+	if !reflect.DeepEqual(written, expected) {
+		t.Errorf("expected %v to be written, but got %v", expected, written)
+	}
+}
@@ -0,0 +1,311 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package camera
+
+import (
+	"fmt"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/tracker"
+	"github.com/sirupsen/logrus"
+	"gocv.io/x/gocv"
+	"image"
+	"io"
+	"reflect"
+	"sort"
+)
+
+const (
+	// detections of the same label overlapping more than this (intersection over union) are merged
+	overlapThreshold = 0.45
+)
+
+// Detection is an object found in a frame, in the coordinates of the full size frame
+type Detection struct {
+	Label string
+	// Between 0 and 1. Haar cascades do not report a confidence, so their detections are always 1.
+	Confidence float64
+	Rect       image.Rectangle
+
+	drawOptions DrawOptions
+	saveCrops   bool
+}
+
+// Detector finds objects in video frames
+type Detector interface {
+	Detect(frame gocv.Mat) []Detection
+	io.Closer
+}
+
+// newDetectors loads the enabled detection profiles. Every Haar profile is run by a single detector, so
+// that frames are only scaled down once. Profiles which fail to load are logged and skipped, so that a
+// recording is never lost to a missing model.
+func newDetectors(width int, height int) []Detector {
+	haar := &haarDetector{
+		width:        width,
+		height:       height,
+		processFrame: gocv.NewMat(),
+	}
+	var detectors []Detector
+	for _, profile := range config.AppConfig.DetectionProfiles {
+		if profile.Disabled {
+			continue
+		}
+
+		if profile.Detector == config.DetectorDNN {
+			detector, err := newDNNDetector(profile)
+			if err != nil {
+				logrus.Errorf("unable to load detection profile %s: %v", profile.Name, err)
+				continue
+			}
+			detectors = append(detectors, detector)
+			continue
+		}
+
+		classifier := gocv.NewCascadeClassifier()
+		if !classifier.Load(cascadeFolder + "/" + profile.ModelFile) {
+			logrus.Errorf("error reading cascade file: %v", profile.ModelFile)
+			continue
+		}
+		haar.cascades = append(haar.cascades, newCascade(profile, &classifier))
+	}
+
+	if len(haar.cascades) == 0 {
+		safeClose(haar)
+		return detectors
+	}
+	return append([]Detector{haar}, detectors...)
+}
+
+// haarDetector runs Haar cascades over a scaled down copy of the frame
+type haarDetector struct {
+	width, height int
+	cascades      []*Cascade
+	processFrame  gocv.Mat
+}
+
+func (detector *haarDetector) Detect(frame gocv.Mat) []Detection {
+	// Resize smaller for use with the cascade classifiers
+	gocv.Resize(frame, &detector.processFrame, image.Point{}, 1.0/float64(config.AppConfig.ImageProcessScale), 1.0/float64(config.AppConfig.ImageProcessScale), gocv.InterpolationLinear)
+
+	var detections []Detection
+	for _, cascade := range detector.cascades {
+		params := cascade.detectParams
+
+		var rects []image.Rectangle
+		if reflect.DeepEqual(params, DetectParams{}) {
+			rects = cascade.classifier.DetectMultiScale(detector.processFrame)
+		} else {
+			rects = cascade.classifier.DetectMultiScaleWithParams(detector.processFrame, params.scale, params.minNeighbors, params.flags,
+				image.Point{X: int(float64(detector.width) * params.minScaleX), Y: int(float64(detector.height) * params.minScaleY)},
+				image.Point{X: int(float64(detector.width) * params.maxScaleX), Y: int(float64(detector.height) * params.maxScaleY)})
+		}
+
+		for _, rect := range rects {
+			detections = append(detections, Detection{
+				Label:       cascade.name,
+				Confidence:  1,
+				Rect:        transformProcessRect(rect),
+				drawOptions: cascade.drawOptions,
+				saveCrops:   cascade.saveCrops,
+			})
+		}
+	}
+	return detections
+}
+
+func (detector *haarDetector) Close() error {
+	safeClose(&detector.processFrame)
+	for _, cascade := range detector.cascades {
+		safeClose(cascade.classifier)
+	}
+	return nil
+}
+
+// dnnDetector runs an SSD or YOLO style neural network model on the CPU
+type dnnDetector struct {
+	net gocv.Net
+	// names of every unconnected output layer. YOLOv3 detects at three scales (two for tiny YOLO), each
+	// in its own output layer.
+	outputNames   []string
+	format        string
+	labels        []string
+	size          image.Point
+	scaleFactor   float64
+	swapRB        bool
+	minConfidence float64
+	drawOptions   DrawOptions
+	saveCrops     bool
+}
+
+func newDNNDetector(profile config.DetectionProfile) (*dnnDetector, error) {
+	net := gocv.ReadNet(profile.ModelFile, profile.ConfigFile)
+	if net.Empty() {
+		return nil, fmt.Errorf("error reading network model %v, %v", profile.ModelFile, profile.ConfigFile)
+	}
+	if err := net.SetPreferableBackend(gocv.NetBackendDefault); err != nil {
+		safeClose(&net)
+		return nil, err
+	}
+	if err := net.SetPreferableTarget(gocv.NetTargetCPU); err != nil {
+		safeClose(&net)
+		return nil, err
+	}
+
+	var outputNames []string
+	for _, id := range net.GetUnconnectedOutLayers() {
+		layer := net.GetLayer(id)
+		outputNames = append(outputNames, layer.GetName())
+		safeClose(&layer)
+	}
+
+	return &dnnDetector{
+		net:           net,
+		outputNames:   outputNames,
+		format:        profile.Format,
+		labels:        profile.Labels,
+		size:          image.Point{X: profile.InputWidth, Y: profile.InputHeight},
+		scaleFactor:   profile.ScaleFactor,
+		swapRB:        profile.SwapRB,
+		minConfidence: profile.MinConfidence,
+		drawOptions:   newDrawOptions(profile),
+		saveCrops:     profile.SaveCrops,
+	}, nil
+}
+
+func (detector *dnnDetector) Detect(frame gocv.Mat) []Detection {
+	blob := gocv.BlobFromImage(frame, detector.scaleFactor, detector.size, gocv.NewScalar(0, 0, 0, 0), detector.swapRB, false)
+	defer safeClose(&blob)
+
+	detector.net.SetInput(blob, "")
+	outputs := detector.forward()
+
+	bounds := image.Rect(0, 0, frame.Cols(), frame.Rows())
+	var detections []Detection
+	for i := range outputs {
+		if detector.format == config.DNNModelFormatYOLO {
+			detections = append(detections, detector.parseYOLO(outputs[i], bounds)...)
+		} else {
+			detections = append(detections, detector.parseSSD(outputs[i], bounds)...)
+		}
+		safeClose(&outputs[i])
+	}
+
+	if detector.format == config.DNNModelFormatYOLO {
+		return suppressOverlaps(detections, overlapThreshold)
+	}
+	return detections
+}
+
+// forward runs the network and returns every output layer, as Forward("") only returns the last one
+func (detector *dnnDetector) forward() []gocv.Mat {
+	if len(detector.outputNames) == 0 {
+		return []gocv.Mat{detector.net.Forward("")}
+	}
+	return detector.net.ForwardLayers(detector.outputNames)
+}
+
+// parseSSD reads an output of [1, 1, N, 7], where each detection is
+// [image, class, confidence, left, top, right, bottom] relative to the size of the frame
+func (detector *dnnDetector) parseSSD(output gocv.Mat, bounds image.Rectangle) []Detection {
+	rows := output.Reshape(1, output.Total()/7)
+	defer safeClose(&rows)
+
+	var detections []Detection
+	for i := 0; i < rows.Rows(); i++ {
+		confidence := float64(rows.GetFloatAt(i, 2))
+		if confidence < detector.minConfidence {
+			continue
+		}
+		rect := image.Rect(
+			int(float64(rows.GetFloatAt(i, 3))*float64(bounds.Dx())),
+			int(float64(rows.GetFloatAt(i, 4))*float64(bounds.Dy())),
+			int(float64(rows.GetFloatAt(i, 5))*float64(bounds.Dx())),
+			int(float64(rows.GetFloatAt(i, 6))*float64(bounds.Dy())),
+		).Intersect(bounds)
+		if rect.Empty() {
+			continue
+		}
+		detections = append(detections, detector.newDetection(int(rows.GetFloatAt(i, 1)), confidence, rect))
+	}
+	return detections
+}
+
+// parseYOLO reads an output of [N, 5 + classes], where each detection is
+// [center x, center y, width, height, objectness, class scores...] relative to the size of the frame
+func (detector *dnnDetector) parseYOLO(output gocv.Mat, bounds image.Rectangle) []Detection {
+	var detections []Detection
+	for i := 0; i < output.Rows(); i++ {
+		class, confidence := -1, 0.0
+		for col := 5; col < output.Cols(); col++ {
+			if score := float64(output.GetFloatAt(i, col)); score > confidence {
+				class, confidence = col-5, score
+			}
+		}
+		if class < 0 || confidence < detector.minConfidence {
+			continue
+		}
+
+		centerX := float64(output.GetFloatAt(i, 0)) * float64(bounds.Dx())
+		centerY := float64(output.GetFloatAt(i, 1)) * float64(bounds.Dy())
+		width := float64(output.GetFloatAt(i, 2)) * float64(bounds.Dx())
+		height := float64(output.GetFloatAt(i, 3)) * float64(bounds.Dy())
+		rect := image.Rect(int(centerX-width/2), int(centerY-height/2), int(centerX+width/2), int(centerY+height/2)).Intersect(bounds)
+		if rect.Empty() {
+			continue
+		}
+		detections = append(detections, detector.newDetection(class, confidence, rect))
+	}
+	return detections
+}
+
+func (detector *dnnDetector) newDetection(class int, confidence float64, rect image.Rectangle) Detection {
+	label := fmt.Sprintf("class_%d", class)
+	if class >= 0 && class < len(detector.labels) {
+		label = detector.labels[class]
+	}
+
+	drawOptions := detector.drawOptions
+	if drawOptions.annotation == "" {
+		drawOptions.annotation = fmt.Sprintf("%s %.0f%%", label, confidence*100)
+	}
+	return Detection{Label: label, Confidence: confidence, Rect: rect, drawOptions: drawOptions, saveCrops: detector.saveCrops}
+}
+
+func (detector *dnnDetector) Close() error {
+	return detector.net.Close()
+}
+
+// suppressOverlaps keeps only the most confident of the detections of the same label which overlap each other
+func suppressOverlaps(detections []Detection, threshold float64) []Detection {
+	sort.SliceStable(detections, func(i, j int) bool {
+		return detections[i].Confidence > detections[j].Confidence
+	})
+
+	var kept []Detection
+	for _, detection := range detections {
+		overlapping := false
+		for _, other := range kept {
+			if other.Label == detection.Label && tracker.IoU(other.Rect, detection.Rect) > threshold {
+				overlapping = true
+				break
+			}
+		}
+		if !overlapping {
+			kept = append(kept, detection)
+		}
+	}
+	return kept
+}
+
+// trackerDetections converts detections for use with the tracker
+func trackerDetections(detections []Detection) []tracker.Detection {
+	converted := make([]tracker.Detection, len(detections))
+	for i, detection := range detections {
+		converted[i] = tracker.Detection{Label: detection.Label, Confidence: detection.Confidence, Rect: detection.Rect}
+	}
+	return converted
+}
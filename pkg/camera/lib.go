@@ -7,13 +7,14 @@
 package camera
 
 import (
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/tracker"
 	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
 	"gocv.io/x/gocv"
-	"golang.org/x/sync/semaphore"
 	"image"
 	"image/color"
 	"io"
@@ -34,11 +35,12 @@ const (
 	textPadding   = 5
 
 	fileMode = 0777
+
+	// how long to wait for the video stream to produce a frame before giving up on a recording
+	liveFrameTimeout = 10 * time.Second
 )
 
 var (
-	cameraSemaphone = semaphore.NewWeighted(1)
-
 	red    = color.RGBA{255, 0, 0, 0}
 	green  = color.RGBA{0, 255, 0, 0}
 	blue   = color.RGBA{0, 0, 255, 0}
@@ -47,126 +49,12 @@ var (
 	purple = color.RGBA{255, 0, 255, 0}
 
 	debugStatsColor = green
-
-	cascadeFiles []CascadeFile
 )
 
 func convertColor(c float64) color.RGBA {
 	return color.RGBA{R: uint8(uint32(c) >> 16 & 0xff), G: uint8(uint32(c) >> 8 & 0xff), B: uint8(uint32(c) & 0xff), A: 0}
 }
 
-func SetupCascadeFiles() {
-	logrus.Debug("SetupCascadeFiles()")
-
-	if config.AppConfig.EnableFaceDetection {
-		cascadeFiles = append(cascadeFiles, CascadeFile{
-			name:     "face",
-			filename: config.AppConfig.FaceDetectionXmlFile,
-			drawOptions: DrawOptions{
-				annotation: config.AppConfig.FaceDetectionAnnotation,
-				color:      convertColor(config.AppConfig.FaceDetectionColor),
-				thickness:  2,
-			},
-			detectParams: DetectParams{
-				scale:        1.4,
-				minNeighbors: 4,
-				flags:        0,
-				minScaleX:    0.05,
-				minScaleY:    0.05,
-				maxScaleX:    0.8,
-				maxScaleY:    0.8,
-			},
-		})
-	}
-
-	if config.AppConfig.EnableProfileFaceDetection {
-		cascadeFiles = append(cascadeFiles, CascadeFile{
-			name:     "profile_face",
-			filename: config.AppConfig.ProfileFaceDetectionXmlFile,
-			drawOptions: DrawOptions{
-				annotation: config.AppConfig.ProfileFaceDetectionAnnotation,
-				color:      convertColor(config.AppConfig.ProfileFaceDetectionColor),
-				thickness:  2,
-			},
-			detectParams: DetectParams{
-				scale:        1.4,
-				minNeighbors: 4,
-				flags:        0,
-				minScaleX:    0.1,
-				minScaleY:    0.1,
-				maxScaleX:    0.8,
-				maxScaleY:    0.8,
-			},
-		})
-	}
-
-	if config.AppConfig.EnableUpperBodyDetection {
-		cascadeFiles = append(cascadeFiles, CascadeFile{
-			name:     "upper_body",
-			filename: config.AppConfig.UpperBodyDetectionXmlFile,
-			drawOptions: DrawOptions{
-				color:      convertColor(config.AppConfig.UpperBodyDetectionColor),
-				thickness:  2,
-				annotation: config.AppConfig.UpperBodyDetectionAnnotation,
-			},
-			detectParams: DetectParams{
-				scale:        1.5,
-				minNeighbors: 3,
-				flags:        0,
-				minScaleX:    0.1,
-				minScaleY:    0.1,
-				maxScaleX:    0.75,
-				maxScaleY:    0.75,
-			},
-		})
-	}
-
-	if config.AppConfig.EnableFullBodyDetection {
-		cascadeFiles = append(cascadeFiles, CascadeFile{
-			name:     "full_body",
-			filename: config.AppConfig.FullBodyDetectionXmlFile,
-			drawOptions: DrawOptions{
-				color:      convertColor(config.AppConfig.FullBodyDetectionColor),
-				thickness:  2,
-				annotation: config.AppConfig.FullBodyDetectionAnnotation,
-			},
-			detectParams: DetectParams{
-				scale:        1.4,
-				minNeighbors: 2,
-				flags:        0,
-				minScaleX:    0.1,
-				minScaleY:    0.1,
-				maxScaleX:    0.6,
-				maxScaleY:    0.8,
-			},
-		})
-	}
-
-	if config.AppConfig.EnableEyeDetection {
-		cascadeFiles = append(cascadeFiles, CascadeFile{
-			name:     "eye",
-			filename: config.AppConfig.EyeDetectionXmlFile,
-			drawOptions: DrawOptions{
-				color:          convertColor(config.AppConfig.EyeDetectionColor),
-				thickness:      1,
-				renderAsCircle: true,
-			},
-			detectParams: DetectParams{
-				scale:        1.5,
-				minNeighbors: 5,
-				flags:        0,
-				minScaleX:    0.01,
-				minScaleY:    0.01,
-				maxScaleX:    0.025,
-				maxScaleY:    0.025,
-			},
-		})
-	}
-
-	logrus.Debug("Enabled OpenCV Detections: ", cascadeFiles)
-	logrus.Debug("SetupCascadeFiles() complete.")
-}
-
 // codecToFloat64 returns a float64 representation of FourCC bytes for use with `gocv.VideoCaptureFOURCC`
 func codecToFloat64(codec string) float64 {
 	if len(codec) != 4 {
@@ -179,63 +67,19 @@ func codecToFloat64(codec string) float64 {
 	return float64((c1 & 255) + ((c2 & 255) << 8) + ((c3 & 255) << 16) + ((c4 & 255) << 24))
 }
 
-func (recorder *Recorder) Open() error {
+// Open gets the recorder ready to record on the camera, whose lock the caller holds
+func (recorder *Recorder) Open(cam *Camera) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			logrus.Errorf("recovered from panic: %+v", r)
+			err = fmt.Errorf("panic while opening the recorder for camera %s: %v", cam.Name, r)
 		}
 	}()
 
 	logrus.Debug("Open()")
-	var err error
-
-	if recorder.webcam, err = gocv.OpenVideoCapture(recorder.videoDevice); err != nil {
-		return errors.Wrapf(err, "Error opening video capture device: %+v", recorder.videoDevice)
-	}
-
-	// Note: setting the video capture four cc is very important for performance reasons.
-	// 		 it should also be set before applying any size or fps configurations.
-	if config.AppConfig.VideoCaptureFOURCC != "" {
-		recorder.webcam.Set(gocv.VideoCaptureFOURCC, codecToFloat64(config.AppConfig.VideoCaptureFOURCC))
-	}
-	if recorder.width != 0 {
-		recorder.webcam.Set(gocv.VideoCaptureFrameWidth, float64(recorder.width))
-	}
-	if recorder.height != 0 {
-		recorder.webcam.Set(gocv.VideoCaptureFrameHeight, float64(recorder.height))
-	}
-	if recorder.fps != 0 {
-		recorder.webcam.Set(gocv.VideoCaptureFPS, recorder.fps)
-	}
-	if config.AppConfig.VideoCaptureBufferSize != 0 {
-		recorder.webcam.Set(gocv.VideoCaptureBufferSize, float64(config.AppConfig.VideoCaptureBufferSize))
-	}
-
-	// load classifier to recognize faces
-	for _, cascadeFile := range cascadeFiles {
-		classifier := gocv.NewCascadeClassifier()
-		if !classifier.Load(cascadeFolder + "/" + cascadeFile.filename) {
-			logrus.Errorf("error reading cascade file: %v", cascadeFile.filename)
-			continue
-		}
-
-		recorder.cascades = append(recorder.cascades, cascadeFile.AsNewCascade(&classifier))
-	}
-
-	//caffeModel := "/opt/intel/openvino/models/intel/face-detection-retail-0004/INT8/face-detection-retail-0004.bin"
-	//protoModel := "/opt/intel/openvino/models/intel/face-detection-retail-0004/INT8/face-detection-retail-0004.xml"
-	//recorder.net = gocv.ReadNet(caffeModel, protoModel)
-	//if recorder.net.Empty() {
-	//	return fmt.Errorf("error reading network model %v, %v", caffeModel, protoModel)
-	//}
-	//
-	//recorder.net.SetPreferableBackend(gocv.NetBackendType(gocv.NetBackendOpenVINO))
-	//recorder.net.SetPreferableTarget(gocv.NetTargetType(gocv.NetTargetCPU))
-
-	// skip the first few frames (sometimes it takes longer to read, which affects the smoothness of the video)
-	recorder.webcam.Grab(config.AppConfig.VideoCaptureBufferSize)
 
-	logrus.Debugf("input codec: %s", recorder.webcam.CodecString())
+	// the object detectors to recognize faces and people are loaded once per camera
+	recorder.detectors = cam.loadDetectors(recorder.width, recorder.height)
 
 	if err = os.MkdirAll(recorder.outputFolder, fileMode); err != nil {
 		return err
@@ -266,16 +110,6 @@ func (recorder *Recorder) writeFrame(filename string) {
 	}(cloneFrame)
 }
 
-func (recorder *Recorder) writeFrameRegion(filename string, region image.Rectangle) {
-	logrus.Debugf("writing image region: %s (%+v)", filename, region)
-	regionMat := recorder.frame.Region(region)
-	cloneFrame := regionMat.Clone()
-	go func(cloneFrame gocv.Mat) {
-		gocv.IMWrite(filepath.Join(recorder.outputFolder, filename), cloneFrame)
-		safeClose(&cloneFrame)
-	}(cloneFrame)
-}
-
 // transformProcessRect takes a smaller scaled rectangle produced by a processing function and transforms it
 // into a rectangle relative to the full original image size
 func transformProcessRect(rect image.Rectangle) image.Rectangle {
@@ -301,14 +135,9 @@ func (recorder *Recorder) Close() {
 	logrus.Debug("Close()")
 
 	safeClose(&recorder.frame)
-	safeClose(&recorder.processFrame)
 
-	safeClose(recorder.webcam)
 	safeClose(recorder.writer)
-	for _, cascade := range recorder.cascades {
-		safeClose(cascade.classifier)
-	}
-	//safeClose(&recorder.net)
+	safeClose(recorder.bestShots)
 	if recorder.liveView {
 		safeClose(recorder.window)
 	}
@@ -345,40 +174,100 @@ func SanityCheck() (bool, error) {
 
 	logrus.Debug("SanityCheck()")
 
-	SetupCascadeFiles()
+	SetupCameras()
+
+	for _, cam := range cameras {
+		metadata, err := RecordVideoToDisk(cam, helper.UnixMilliNow(), 3.0/float64(config.AppConfig.VideoOutputFps), filepath.Join("/tmp", cam.Name), false)
+		recorded := metadata != nil
+		logrus.Debugf("SanityCheck() camera %s returned: %v, %+v", cam.Name, recorded, err)
+		if err != nil || !recorded {
+			return recorded, errors.Wrapf(err, "sanity check failed for camera %s", cam.Name)
+		}
+	}
+
+	logrus.Debug("SanityCheck() complete.")
+	return true, nil
+}
+
+// readLiveFrame waits for the next frame from the video stream and copies it into recorder.frame,
+// returning the time it was captured
+func (recorder *Recorder) readLiveFrame(live chan Frame) (int64, error) {
+	select {
+	case frame := <-live:
+		frame.mat.CopyTo(&recorder.frame)
+		safeClose(&frame.mat)
+		return frame.timestamp, nil
+	case <-time.After(liveFrameTimeout):
+		return 0, fmt.Errorf("timed out waiting for a frame from video device: %+v", recorder.videoDevice)
+	}
+}
+
+// RecordVideoToDisk records a video, along with its thumbnail and object detections, to the output folder.
+// The video starts with the buffered frames from preRecordingDuration before triggeredOn (milliseconds since
+// the epoch), followed by seconds of live video. It returns the metadata of the recording, or nil if the camera
+// is already recording.
+func RecordVideoToDisk(cam *Camera, triggeredOn int64, seconds float64, outputFolder string, liveView bool) (*Metadata, error) {
+	// only allow one recording at a time per camera
+	if !cam.semaphore.TryAcquire(1) {
+		logrus.Warnf("unable to acquire camera lock, camera %s must already be recording. skipping.", cam.Name)
+		return nil, nil
+	}
+	defer cam.semaphore.Release(1)
 
-	recorded, err := RecordVideoToDisk(config.AppConfig.VideoDevice, 3.0/float64(config.AppConfig.VideoOutputFps), "/tmp", false)
-	logrus.Debugf("SanityCheck() complete. Returned: %v, %+v", recorded, err)
-	return recorded, err
+	return recordVideoToDisk(cam, triggeredOn, seconds, outputFolder, liveView)
 }
 
-func RecordVideoToDisk(videoDevice string, seconds float64, outputFolder string, liveView bool) (bool, error) {
+// WaitAndRecordVideoToDisk is the same as RecordVideoToDisk, except that it waits for any recording already
+// in progress on the camera to finish instead of skipping the recording
+func WaitAndRecordVideoToDisk(cam *Camera, triggeredOn int64, seconds float64, outputFolder string, liveView bool) (*Metadata, error) {
+	if err := cam.semaphore.Acquire(context.Background(), 1); err != nil {
+		return nil, errors.Wrapf(err, "unable to acquire camera lock for camera %s", cam.Name)
+	}
+	defer cam.semaphore.Release(1)
+
+	return recordVideoToDisk(cam, triggeredOn, seconds, outputFolder, liveView)
+}
+
+// recordVideoToDisk records a video on a camera the caller holds the lock of
+func recordVideoToDisk(cam *Camera, triggeredOn int64, seconds float64, outputFolder string, liveView bool) (metadata *Metadata, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			logrus.Errorf("recovered from panic: %+v", r)
+			metadata, err = nil, fmt.Errorf("panic while recording on camera %s: %v", cam.Name, r)
 		}
 	}()
 
-	// only allow one recording at a time
-	// also we do not want to queue up recordings because they would be at invalid times anyways
-	if !cameraSemaphone.TryAcquire(1) {
-		logrus.Warn("unable to acquire camera lock, we must already be recording. skipping.")
-		return false, nil
+	recorder := NewRecorder(cam, outputFolder, liveView)
+	recorder.metadata.TriggeredOn = triggeredOn
+	if recorder.stream, err = GetOrStartStream(recorder.videoDevice); err != nil {
+		return nil, err
 	}
-	defer cameraSemaphone.Release(1)
 
-	recorder := NewRecorder(videoDevice, outputFolder, liveView)
-	if err := recorder.Open(); err != nil {
+	// the stream is subscribed to before loading the detectors and opening the video writer, so that
+	// the pre-roll is taken relative to the trigger and no live frame is missed in the meantime
+	since := triggeredOn - int64(config.AppConfig.PreRecordingDuration)*1000
+	preRoll, live := recorder.stream.Subscribe(since)
+	defer recorder.stream.Unsubscribe(live)
+	defer func() {
+		for _, frame := range preRoll {
+			safeClose(&frame.mat)
+		}
+	}()
+	if len(preRoll) > 0 && preRoll[0].timestamp > triggeredOn {
+		logrus.Warnf("camera %s has no buffered frames from before the trigger, the recording starts %d ms after it",
+			cam.Name, preRoll[0].timestamp-triggeredOn)
+	}
+
+	if err := recorder.Open(cam); err != nil {
 		logrus.Errorf("error: %v", err)
-		return false, err
+		return nil, err
 	}
 
 	defer recorder.Close()
 
-	var err error
 	recorder.writer, err = gocv.VideoWriterFile(recorder.outputFilename, recorder.codec, recorder.fps, recorder.width, recorder.height, true)
 	if err != nil {
-		return false, errors.Wrapf(err, "error opening video writer device: %+v", recorder.outputFilename)
+		return nil, errors.Wrapf(err, "error opening video writer device: %+v", recorder.outputFilename)
 	}
 
 	if recorder.liveView {
@@ -391,8 +280,17 @@ func RecordVideoToDisk(videoDevice string, seconds float64, outputFolder string,
 	}
 	begin := time.Now()
 
-	recorder.frameCount = int(math.Round(recorder.fps * seconds))
-	var rects []image.Rectangle
+	// the pre-roll frames are written ahead of the live frames
+	recorder.frameCount = len(preRoll) + int(math.Round(recorder.fps*seconds))
+	if config.AppConfig.MaxRecordingDuration > 0 {
+		recorder.maxFrameCount = len(preRoll) + int(math.Round(recorder.fps*float64(config.AppConfig.MaxRecordingDuration)))
+	}
+	logrus.Debugf("recording %d pre-roll frames and %d live frames", len(preRoll), recorder.frameCount-len(preRoll))
+	recorder.metadata.PreRollFrames = len(preRoll)
+
+	// allow the recording to be extended while it is in progress
+	cam.setRecorder(recorder)
+	defer cam.setRecorder(nil)
 	// for debug stats
 	var read, process, total DebugStats
 	var prevMillis, currentMills, startTS, readTS, processedTS int64
@@ -402,12 +300,20 @@ func RecordVideoToDisk(videoDevice string, seconds float64, outputFolder string,
 	yPadding := 35
 	yStart := 0
 
-	for i := 0; i < recorder.frameCount; i++ {
+	for i := 0; ; i++ {
+		frameCount, ok := cam.nextFrame(recorder, i)
+		if !ok {
+			break
+		}
 
 		startTS = helper.UnixMilliNow()
 
-		if ok := recorder.webcam.Read(&recorder.frame); !ok {
-			return false, fmt.Errorf("unable to read from webcam. device closed: %+v", recorder.videoDevice)
+		var capturedTS int64
+		if i < len(preRoll) {
+			preRoll[i].mat.CopyTo(&recorder.frame)
+			capturedTS = preRoll[i].timestamp
+		} else if capturedTS, err = recorder.readLiveFrame(live); err != nil {
+			return nil, err
 		}
 		readTS = helper.UnixMilliNow()
 
@@ -419,56 +325,57 @@ func RecordVideoToDisk(videoDevice string, seconds float64, outputFolder string,
 		if err := recorder.writer.Write(recorder.frame); err != nil {
 			logrus.Errorf("error occurred while writing video to disk: %v", err)
 		}
+		// empty frames are not part of the video, so this is the index of the frame in the video
+		frameIndex := len(recorder.metadata.FrameTimestamps)
+		recorder.metadata.FrameTimestamps = append(recorder.metadata.FrameTimestamps, capturedTS)
 
 		switch i {
 		case 0:
 			recorder.writeFrame("frame.first.jpg")
 			recorder.writeThumb("thumb.jpg")
-		case recorder.frameCount / 2:
+		case frameCount / 2:
 			recorder.writeFrame("frame.middle.jpg")
-		case recorder.frameCount - 1:
+		case frameCount - 1:
 			recorder.writeFrame("frame.last.jpg")
 		default:
 			break
 		}
 
-		if len(recorder.cascades) > 0 {
-			// Resize smaller for use with the cascade classifiers
-			gocv.Resize(recorder.frame, &recorder.processFrame, image.Point{}, 1.0/float64(config.AppConfig.ImageProcessScale), 1.0/float64(config.AppConfig.ImageProcessScale), gocv.InterpolationLinear)
+		// detection is slower than the video stream, so when the recording falls behind it skips detection
+		// on some frames rather than letting the stream drop frames from the video
+		behind := len(live) > cap(live)/2
+		if behind && len(recorder.detectors) > 0 {
+			recorder.metadata.DetectionSkippedFrames++
+			logrus.Tracef("recording is %d frames behind the video stream, skipping detection", len(live))
+		}
 
-			recorder.overlays = nil
-			for _, cascade := range recorder.cascades {
-				params := cascade.detectParams
+		if !behind && len(recorder.detectors) > 0 {
+			var detections []Detection
+			for _, detector := range recorder.detectors {
+				detections = append(detections, detector.Detect(recorder.frame)...)
+			}
 
-				if reflect.DeepEqual(params, DetectParams{}) {
-					rects = cascade.classifier.DetectMultiScale(recorder.processFrame)
-				} else {
-					rects = cascade.classifier.DetectMultiScaleWithParams(recorder.processFrame, params.scale, params.minNeighbors, params.flags,
-						image.Point{X: int(float64(recorder.width) * params.minScaleX), Y: int(float64(recorder.height) * params.minScaleY)},
-						image.Point{X: int(float64(recorder.width) * params.maxScaleX), Y: int(float64(recorder.height) * params.maxScaleY)})
+			recorder.overlays = nil
+			tracks := recorder.tracker.Update(frameIndex, trackerDetections(detections))
+			for d, detection := range detections {
+				track := tracks[d]
+				if track.FirstFrame == frameIndex {
+					logrus.Debugf("Detected new %s (track %d)", detection.Label, track.ID)
 				}
-
-				if len(rects) > 0 {
-					if cascade.found < len(rects) {
-						cascade.found = len(rects)
-						logrus.Debugf("Detected %v %s(s)", len(rects), cascade.name)
-
-						if config.AppConfig.SaveObjectDetectionsToDisk {
-							for i, rect := range rects {
-								recorder.writeFrameRegion(fmt.Sprintf("%s.%d.jpg", cascade.name, i+cascade.written), transformProcessRect(rect))
-							}
-							// this keeps track of how many we have written before. so if we see 1 face and write it, then see 2 faces, it will not overwrite the first face found
-							cascade.written += cascade.found
-						}
-					} else {
-						logrus.Tracef("Detected %v %s(s)", len(rects), cascade.name)
-					}
-
-					if liveView {
-						for _, rect := range rects {
-							recorder.overlays = append(recorder.overlays, FrameOverlay{rect: transformProcessRect(rect), drawOptions: cascade.drawOptions})
-						}
-					}
+				// the best crops of each object are written once the recording is done
+				if config.AppConfig.SaveObjectDetectionsToDisk && detection.saveCrops {
+					recorder.bestShots.offer(track, recorder.frame, detection, len(recorder.metadata.Detections))
+				}
+				recorder.metadata.Detections = append(recorder.metadata.Detections, DetectionMetadata{
+					Label:      detection.Label,
+					Confidence: detection.Confidence,
+					Box:        tracker.NewBox(detection.Rect),
+					Frame:      frameIndex,
+					Track:      track.ID,
+				})
+
+				if liveView {
+					recorder.overlays = append(recorder.overlays, FrameOverlay{rect: detection.Rect, drawOptions: detection.drawOptions})
 				}
 			}
 		}
@@ -537,5 +444,12 @@ func RecordVideoToDisk(videoDevice string, seconds float64, outputFolder string,
 
 	logrus.Debugf("recording took %v", time.Now().Sub(begin))
 
-	return true, nil
+	recorder.bestShots.write(recorder.outputFolder, recorder.metadata.Detections)
+	recorder.metadata.Tracks = recorder.tracker.Tracks()
+	recorder.metadata.DroppedFrames = recorder.stream.Dropped(live)
+	if recorder.metadata.DroppedFrames > 0 {
+		logrus.Warnf("%d frames were dropped from the recording on camera %s", recorder.metadata.DroppedFrames, cam.Name)
+	}
+
+	return recorder.metadata, nil
 }
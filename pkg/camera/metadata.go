@@ -0,0 +1,62 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package camera
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/tracker"
+)
+
+// Metadata describes a finished recording: the camera settings it was recorded with, when each of its
+// frames was captured, and every object detected in it
+type Metadata struct {
+	Camera      string  `json:"camera"`
+	VideoDevice string  `json:"video_device"`
+	Video       string  `json:"video"`
+	Thumb       string  `json:"thumb"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	FPS         float64 `json:"fps"`
+	Codec       string  `json:"codec"`
+	// When the recording was triggered, in milliseconds since the epoch
+	TriggeredOn int64 `json:"triggered_on"`
+	// Frames taken from the pre-roll buffer at the start of the video, captured from preRecordingDuration
+	// before the trigger until the recording started
+	PreRollFrames int `json:"pre_roll_frames"`
+	// Capture time of each frame of the video, in milliseconds since the epoch
+	FrameTimestamps []int64 `json:"frame_timestamps"`
+	// Frames missing from the video because the recording fell too far behind the video stream
+	DroppedFrames int `json:"dropped_frames"`
+	// Frames of the video which were not run through the object detectors, to keep up with the video stream
+	DetectionSkippedFrames int `json:"detection_skipped_frames"`
+	// Every object detected, in the order of the frames
+	Detections []DetectionMetadata `json:"detections"`
+	// Every object followed across frames, ordered by track id
+	Tracks []tracker.Track `json:"tracks"`
+}
+
+// DetectionMetadata is an object detected in a single frame of a recording
+type DetectionMetadata struct {
+	Label      string      `json:"label"`
+	Confidence float64     `json:"confidence"`
+	Box        tracker.Box `json:"box"`
+	// Index of the frame in the video, and in FrameTimestamps
+	Frame int `json:"frame"`
+	Track int `json:"track"`
+	// Filename of the crop of this detection, if it was one of the best shots of its track
+	Crop string `json:"crop,omitempty"`
+}
+
+// Crops returns the filenames of every crop written to the recording folder
+func (metadata *Metadata) Crops() []string {
+	var crops []string
+	for _, detection := range metadata.Detections {
+		if detection.Crop != "" {
+			crops = append(crops, detection.Crop)
+		}
+	}
+	return crops
+}
@@ -8,6 +8,7 @@ package camera
 
 import (
 	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/tracker"
 	"gocv.io/x/gocv"
 	"image"
 	"image/color"
@@ -63,6 +64,15 @@ type DrawOptions struct {
 	renderAsCircle bool
 }
 
+func newDrawOptions(profile config.DetectionProfile) DrawOptions {
+	return DrawOptions{
+		annotation:     profile.Annotation,
+		color:          convertColor(float64(profile.RGB())),
+		thickness:      profile.Thickness,
+		renderAsCircle: profile.Circle,
+	}
+}
+
 type DetectParams struct {
 	scale        float64
 	minNeighbors int
@@ -73,28 +83,30 @@ type DetectParams struct {
 	maxScaleY    float64
 }
 
-type CascadeFile struct {
-	name         string
-	filename     string
-	drawOptions  DrawOptions
-	detectParams DetectParams
-}
-
 type Cascade struct {
 	name         string
-	found        int
-	written      int
 	drawOptions  DrawOptions
 	detectParams DetectParams
+	saveCrops    bool
 	classifier   *gocv.CascadeClassifier
 }
 
-func (cascadeFile CascadeFile) AsNewCascade(classifier *gocv.CascadeClassifier) *Cascade {
+// newCascade uses the settings of a Haar detection profile for a loaded classifier
+func newCascade(profile config.DetectionProfile, classifier *gocv.CascadeClassifier) *Cascade {
 	return &Cascade{
-		name:         cascadeFile.name,
-		detectParams: cascadeFile.detectParams,
-		drawOptions:  cascadeFile.drawOptions,
-		classifier:   classifier,
+		name:        profile.Name,
+		drawOptions: newDrawOptions(profile),
+		detectParams: DetectParams{
+			scale:        profile.Scale,
+			minNeighbors: profile.MinNeighbors,
+			flags:        profile.Flags,
+			minScaleX:    profile.MinScaleX,
+			minScaleY:    profile.MinScaleY,
+			maxScaleX:    profile.MaxScaleX,
+			maxScaleY:    profile.MaxScaleY,
+		},
+		saveCrops:  profile.SaveCrops,
+		classifier: classifier,
 	}
 }
 
@@ -109,26 +121,31 @@ type Recorder struct {
 	outputFilename string
 	fps            float64
 	frameCount     int
+	frameIndex     int
+	maxFrameCount  int
 	codec          string
 	width          int
 	height         int
 	liveView       bool
 
-	webcam *gocv.VideoCapture
+	stream *Stream
 	writer *gocv.VideoWriter
 	window *gocv.Window
-	//net	       gocv.Net
 
-	frame        gocv.Mat
-	processFrame gocv.Mat
+	frame gocv.Mat
 
 	overlays []FrameOverlay
-	cascades []*Cascade
+	// the detectors of the camera, which outlive the recording
+	detectors []Detector
+	// follows the detections from frame to frame, so that the crops of each object are kept together
+	tracker   *tracker.Tracker
+	bestShots *bestShots
+	metadata  *Metadata
 }
 
-func NewRecorder(videoDevice string, outputFolder string, liveView bool) *Recorder {
+func NewRecorder(cam *Camera, outputFolder string, liveView bool) *Recorder {
 	recorder := &Recorder{
-		videoDevice:    videoDevice,
+		videoDevice:    cam.VideoDevice,
 		outputFolder:   outputFolder,
 		outputFilename: filepath.Join(outputFolder, "video"+config.AppConfig.VideoOutputExtension),
 		width:          config.AppConfig.VideoResolutionWidth,
@@ -136,9 +153,20 @@ func NewRecorder(videoDevice string, outputFolder string, liveView bool) *Record
 		liveView:       liveView,
 		fps:            float64(config.AppConfig.VideoOutputFps),
 		codec:          config.AppConfig.VideoOutputCodec,
-		window:         gocv.NewWindow(config.AppConfig.ServiceName + " - OpenVINO"),
+		window:         gocv.NewWindow(config.AppConfig.ServiceName + " - " + cam.Name),
 		frame:          gocv.NewMat(),
-		processFrame:   gocv.NewMat(),
+		tracker:        tracker.New(config.AppConfig.TrackMinOverlap, config.AppConfig.TrackMaxDistance, config.AppConfig.TrackMaxMissedFrames),
+		bestShots:      newBestShots(config.AppConfig.BestShotsPerTrack),
+		metadata: &Metadata{
+			Camera:      cam.Name,
+			VideoDevice: cam.VideoDevice,
+			Video:       "video" + config.AppConfig.VideoOutputExtension,
+			Thumb:       "thumb.jpg",
+			Width:       config.AppConfig.VideoResolutionWidth,
+			Height:      config.AppConfig.VideoResolutionHeight,
+			FPS:         float64(config.AppConfig.VideoOutputFps),
+			Codec:       config.AppConfig.VideoOutputCodec,
+		},
 	}
 
 	return recorder
@@ -0,0 +1,173 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package camera
+
+import (
+	"context"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/semaphore"
+	"math"
+	"sync"
+)
+
+var (
+	cameras []*Camera
+)
+
+// Camera is a single video device along with the RSP sensors and antenna aliases it covers.
+// Each camera records independently of the others, so recordings at different exits can run in parallel.
+type Camera struct {
+	Name        string   `json:"name"`
+	VideoDevice string   `json:"-"`
+	DeviceIds   []string `json:"device_ids"`
+	Aliases     []string `json:"aliases"`
+
+	// only allow one recording at a time per camera
+	semaphore *semaphore.Weighted
+
+	// object detectors loaded on the first recording of the camera, and kept until shutdown. Only the
+	// recording holding the semaphore uses them.
+	detectors       []Detector
+	detectorsLoaded bool
+
+	// the recording currently in progress, guarded by mutex so it can be extended while recording
+	mutex    sync.Mutex
+	recorder *Recorder
+}
+
+// NewCamera returns a Camera based on its configuration
+func NewCamera(cfg config.CameraConfig) *Camera {
+	return &Camera{
+		Name:        cfg.Name,
+		VideoDevice: cfg.VideoDevice,
+		DeviceIds:   cfg.DeviceIds,
+		Aliases:     cfg.Aliases,
+		semaphore:   semaphore.NewWeighted(1),
+	}
+}
+
+// SetupCameras builds the camera registry from the loaded configuration. The detectors of the cameras it
+// replaces are closed.
+func SetupCameras() {
+	CloseDetectors()
+	cameras = nil
+	for _, cfg := range config.AppConfig.Cameras {
+		cameras = append(cameras, NewCamera(cfg))
+	}
+	logrus.Debugf("Configured cameras: %+v", cameras)
+}
+
+// CloseDetectors closes the object detectors of every camera, once any recording in progress is done.
+// They are loaded again on the next recording.
+func CloseDetectors() {
+	for _, cam := range cameras {
+		if err := cam.semaphore.Acquire(context.Background(), 1); err != nil {
+			logrus.Errorf("unable to acquire camera lock for camera %s: %v", cam.Name, err)
+			continue
+		}
+		for _, detector := range cam.detectors {
+			safeClose(detector)
+		}
+		cam.detectors, cam.detectorsLoaded = nil, false
+		cam.semaphore.Release(1)
+	}
+}
+
+// loadDetectors returns the object detectors of the camera, loading them on its first recording so that the
+// models are not read again for every recording. The caller must hold the camera lock.
+func (cam *Camera) loadDetectors(width int, height int) []Detector {
+	if !cam.detectorsLoaded {
+		cam.detectors = newDetectors(width, height)
+		cam.detectorsLoaded = true
+	}
+	return cam.detectors
+}
+
+// All returns every camera in the registry
+func All() []*Camera {
+	return cameras
+}
+
+// FindCoveringCameras returns every camera which covers the given RSP device or antenna alias
+func FindCoveringCameras(deviceId string, alias string) []*Camera {
+	var covering []*Camera
+	for _, cam := range cameras {
+		if cam.Covers(deviceId, alias) {
+			covering = append(covering, cam)
+		}
+	}
+	return covering
+}
+
+// Covers returns true if this camera is mapped to the RSP device or antenna alias.
+// A camera without any device ids or aliases covers everything.
+func (cam *Camera) Covers(deviceId string, alias string) bool {
+	if len(cam.DeviceIds) == 0 && len(cam.Aliases) == 0 {
+		return true
+	}
+
+	for _, id := range cam.DeviceIds {
+		if id == deviceId {
+			return true
+		}
+	}
+	for _, a := range cam.Aliases {
+		if a == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtendRecording pushes the end of the recording in progress out so that it lasts at least
+// another seconds, limited to MaxRecordingDuration in total. It returns false if the camera
+// is not currently recording.
+func (cam *Camera) ExtendRecording(seconds float64) bool {
+	cam.mutex.Lock()
+	defer cam.mutex.Unlock()
+
+	recorder := cam.recorder
+	if recorder == nil {
+		return false
+	}
+
+	frameCount := recorder.frameIndex + int(math.Round(recorder.fps*seconds))
+	if recorder.maxFrameCount > 0 && frameCount > recorder.maxFrameCount {
+		logrus.Warnf("unable to extend recording on camera %s past the max recording duration", cam.Name)
+		frameCount = recorder.maxFrameCount
+	}
+	if frameCount > recorder.frameCount {
+		logrus.Debugf("extending recording on camera %s by %d frames", cam.Name, frameCount-recorder.frameCount)
+		recorder.frameCount = frameCount
+	}
+	return true
+}
+
+// setRecorder marks the recorder as the recording in progress for this camera
+func (cam *Camera) setRecorder(recorder *Recorder) {
+	cam.mutex.Lock()
+	cam.recorder = recorder
+	cam.mutex.Unlock()
+}
+
+// nextFrame returns the current frame count of the recording, and whether or not frame i should be recorded.
+// Once it returns false, the recording can no longer be extended.
+func (cam *Camera) nextFrame(recorder *Recorder, i int) (int, bool) {
+	cam.mutex.Lock()
+	defer cam.mutex.Unlock()
+
+	recorder.frameIndex = i
+	if i < recorder.frameCount {
+		return recorder.frameCount, true
+	}
+
+	if cam.recorder == recorder {
+		cam.recorder = nil
+	}
+	return recorder.frameCount, false
+}
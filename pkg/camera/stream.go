@@ -0,0 +1,298 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package camera
+
+import (
+	"fmt"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gocv.io/x/gocv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// how long to wait before attempting to re-open a video device that failed
+	reconnectDelay = 5 * time.Second
+)
+
+var (
+	streams      = make(map[string]*Stream)
+	streamsMutex sync.Mutex
+)
+
+// Frame is a single image read from a video device along with the time it was read
+type Frame struct {
+	mat       gocv.Mat
+	timestamp int64
+}
+
+// sharedFrame is a buffered frame which is never modified once pushed, so that a snapshot can hold on to it
+// and copy it without blocking the capture loop. Its Mat is closed once the buffer and every snapshot
+// holding it have released it.
+type sharedFrame struct {
+	Frame
+	refs int32
+}
+
+func (frame *sharedFrame) retain() {
+	atomic.AddInt32(&frame.refs, 1)
+}
+
+func (frame *sharedFrame) release() {
+	if atomic.AddInt32(&frame.refs, -1) == 0 {
+		safeClose(&frame.mat)
+	}
+}
+
+// FrameBuffer is a fixed size ring buffer of the most recent frames read from a video device
+type FrameBuffer struct {
+	frames []*sharedFrame
+	next   int
+	count  int
+}
+
+// NewFrameBuffer returns a FrameBuffer capable of holding capacity frames
+func NewFrameBuffer(capacity int) *FrameBuffer {
+	return &FrameBuffer{frames: make([]*sharedFrame, capacity)}
+}
+
+// Push copies the frame into the buffer, releasing the oldest frame if the buffer is full
+func (buffer *FrameBuffer) Push(mat gocv.Mat, timestamp int64) {
+	if len(buffer.frames) == 0 {
+		return
+	}
+
+	if oldest := buffer.frames[buffer.next]; oldest != nil {
+		oldest.release()
+	}
+	buffer.frames[buffer.next] = &sharedFrame{Frame: Frame{mat: mat.Clone(), timestamp: timestamp}, refs: 1}
+	buffer.next = (buffer.next + 1) % len(buffer.frames)
+	if buffer.count < len(buffer.frames) {
+		buffer.count++
+	}
+}
+
+// retainSince returns every buffered frame captured at or after the timestamp, oldest first. The frames
+// are only referenced rather than copied, so this is quick enough to call while holding the stream lock.
+// The caller must release every returned frame, see copyFrames.
+func (buffer *FrameBuffer) retainSince(timestamp int64) []*sharedFrame {
+	var retained []*sharedFrame
+	start := (buffer.next - buffer.count + len(buffer.frames)) % len(buffer.frames)
+	for i := 0; i < buffer.count; i++ {
+		frame := buffer.frames[(start+i)%len(buffer.frames)]
+		if frame.timestamp < timestamp {
+			continue
+		}
+		frame.retain()
+		retained = append(retained, frame)
+	}
+	return retained
+}
+
+// copyFrames copies and releases retained frames. The caller is responsible for closing the returned Mats.
+func copyFrames(retained []*sharedFrame) []Frame {
+	frames := make([]Frame, 0, len(retained))
+	for _, frame := range retained {
+		frames = append(frames, Frame{mat: frame.mat.Clone(), timestamp: frame.timestamp})
+		frame.release()
+	}
+	return frames
+}
+
+// Close releases every Mat owned by the buffer
+func (buffer *FrameBuffer) Close() error {
+	for i, frame := range buffer.frames {
+		if frame != nil {
+			frame.release()
+			buffer.frames[i] = nil
+		}
+	}
+	buffer.count = 0
+	return nil
+}
+
+// Stream continuously reads frames from a single video device, keeping the most recent
+// frames in a ring buffer (pre-roll) and handing live frames out to any subscribers
+type Stream struct {
+	videoDevice string
+	webcam      *gocv.VideoCapture
+	buffer      *FrameBuffer
+
+	mutex sync.Mutex
+	// every subscriber, along with how many frames it was too far behind to receive
+	subscribers map[chan Frame]int
+}
+
+// GetOrStartStream returns the running Stream for a video device, starting a new one if needed
+func GetOrStartStream(videoDevice string) (*Stream, error) {
+	streamsMutex.Lock()
+	defer streamsMutex.Unlock()
+
+	if stream, ok := streams[videoDevice]; ok {
+		return stream, nil
+	}
+
+	stream := &Stream{
+		videoDevice: videoDevice,
+		buffer:      NewFrameBuffer(preRollFrameCount()),
+		subscribers: make(map[chan Frame]int),
+	}
+	if err := stream.open(); err != nil {
+		safeClose(stream.buffer)
+		return nil, err
+	}
+
+	streams[videoDevice] = stream
+	go stream.run()
+
+	return stream, nil
+}
+
+// preRollFrameCount returns the amount of frames needed to hold the configured pre-recording duration
+func preRollFrameCount() int {
+	return config.AppConfig.VideoOutputFps * config.AppConfig.PreRecordingDuration
+}
+
+func (stream *Stream) open() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.Errorf("recovered from panic: %+v", r)
+			// the device may be half opened, so it is closed rather than handed to the capture loop
+			if stream.webcam != nil {
+				safeClose(stream.webcam)
+				stream.webcam = nil
+			}
+			err = fmt.Errorf("panic opening video stream %s: %v", stream.videoDevice, r)
+		}
+	}()
+
+	logrus.Debugf("opening video stream: %s", stream.videoDevice)
+
+	if stream.webcam, err = gocv.OpenVideoCapture(stream.videoDevice); err != nil {
+		return errors.Wrapf(err, "Error opening video capture device: %+v", stream.videoDevice)
+	}
+
+	// Note: setting the video capture four cc is very important for performance reasons.
+	// 		 it should also be set before applying any size or fps configurations.
+	if config.AppConfig.VideoCaptureFOURCC != "" {
+		stream.webcam.Set(gocv.VideoCaptureFOURCC, codecToFloat64(config.AppConfig.VideoCaptureFOURCC))
+	}
+	if config.AppConfig.VideoResolutionWidth != 0 {
+		stream.webcam.Set(gocv.VideoCaptureFrameWidth, float64(config.AppConfig.VideoResolutionWidth))
+	}
+	if config.AppConfig.VideoResolutionHeight != 0 {
+		stream.webcam.Set(gocv.VideoCaptureFrameHeight, float64(config.AppConfig.VideoResolutionHeight))
+	}
+	if config.AppConfig.VideoOutputFps != 0 {
+		stream.webcam.Set(gocv.VideoCaptureFPS, float64(config.AppConfig.VideoOutputFps))
+	}
+	if config.AppConfig.VideoCaptureBufferSize != 0 {
+		stream.webcam.Set(gocv.VideoCaptureBufferSize, float64(config.AppConfig.VideoCaptureBufferSize))
+	}
+
+	// skip the first few frames (sometimes it takes longer to read, which affects the smoothness of the video)
+	stream.webcam.Grab(config.AppConfig.VideoCaptureBufferSize)
+
+	logrus.Debugf("input codec: %s", stream.webcam.CodecString())
+	return nil
+}
+
+// run is the capture loop. It reads frames for as long as the service is running, re-opening
+// the video device whenever a read fails.
+func (stream *Stream) run() {
+	frame := gocv.NewMat()
+	defer safeClose(&frame)
+
+	for {
+		if ok := stream.webcam.Read(&frame); !ok {
+			logrus.Errorf("unable to read from webcam. device closed: %+v", stream.videoDevice)
+			stream.reconnect()
+			continue
+		}
+
+		if frame.Empty() {
+			logrus.Trace("skipping empty frame from webcam")
+			continue
+		}
+
+		stream.publish(frame, helper.UnixMilliNow())
+	}
+}
+
+func (stream *Stream) reconnect() {
+	safeClose(stream.webcam)
+	for {
+		time.Sleep(reconnectDelay)
+		if err := stream.open(); err != nil {
+			logrus.Errorf("error re-opening video stream: %v", err)
+			continue
+		}
+		return
+	}
+}
+
+// publish hands a copy of the frame to every subscriber and stores it in the pre-roll buffer
+func (stream *Stream) publish(mat gocv.Mat, timestamp int64) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	for subscriber := range stream.subscribers {
+		clone := mat.Clone()
+		select {
+		case subscriber <- Frame{mat: clone, timestamp: timestamp}:
+		default:
+			logrus.Warn("subscriber is not keeping up with the video stream, dropping frame")
+			stream.subscribers[subscriber]++
+			safeClose(&clone)
+		}
+	}
+
+	stream.buffer.Push(mat, timestamp)
+}
+
+// Subscribe returns a copy of the buffered frames captured at or after since, along with a channel which will
+// receive every frame read after that point. Both are captured atomically so no frame is missed or duplicated.
+// The channel holds enough frames for the whole pre-roll to be written before the recording falls behind.
+// The caller is responsible for closing every Mat it receives, and must call Unsubscribe when done.
+func (stream *Stream) Subscribe(since int64) ([]Frame, chan Frame) {
+	stream.mutex.Lock()
+	live := make(chan Frame, preRollFrameCount()+config.AppConfig.VideoOutputFps)
+	stream.subscribers[live] = 0
+	retained := stream.buffer.retainSince(since)
+	stream.mutex.Unlock()
+
+	// copying a few seconds of full size frames is slow, so it is done without blocking the capture loop
+	return copyFrames(retained), live
+}
+
+// Dropped returns how many frames the subscriber was too far behind to receive
+func (stream *Stream) Dropped(live chan Frame) int {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	return stream.subscribers[live]
+}
+
+// Unsubscribe stops sending frames to the channel and closes any frames left in it
+func (stream *Stream) Unsubscribe(live chan Frame) {
+	stream.mutex.Lock()
+	delete(stream.subscribers, live)
+	stream.mutex.Unlock()
+
+	for {
+		select {
+		case frame := <-live:
+			safeClose(&frame.mat)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,72 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package clock is the source of the current time and the timers used by the trigger logic. It can be overridden
+// to replay captured events as if they were happening at their original time.
+package clock
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
+	"sync"
+	"time"
+)
+
+// Clock tells the current time, and runs functions once a duration has elapsed
+type Clock interface {
+	// Now returns the current time in milliseconds epoch
+	Now() int64
+	// AfterFunc calls f in its own goroutine once d has elapsed, unless the returned timer is stopped first
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a function waiting to be called by a Clock
+type Timer interface {
+	// Stop prevents the function from being called. It returns false if the function was already called or stopped.
+	Stop() bool
+}
+
+// systemClock is the time of the system, and its timers
+type systemClock struct{}
+
+func (systemClock) Now() int64 {
+	return helper.UnixMilliNow()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+var (
+	current Clock = systemClock{}
+	mutex   sync.RWMutex
+)
+
+// Now returns the current time in milliseconds epoch
+func Now() int64 {
+	return get().Now()
+}
+
+// AfterFunc calls f once d has elapsed on the current clock, unless the returned timer is stopped first
+func AfterFunc(d time.Duration, f func()) Timer {
+	return get().AfterFunc(d, f)
+}
+
+// Set overrides the current time and timers. A nil clock goes back to the system time.
+func Set(clock Clock) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if clock == nil {
+		clock = systemClock{}
+	}
+	current = clock
+}
+
+func get() Clock {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return current
+}
@@ -0,0 +1,119 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a clock which only moves when it is advanced, and fires its timers as it moves past them.
+// This makes the timing of replayed events independent of how fast they are replayed.
+type Fake struct {
+	mutex  sync.Mutex
+	now    int64
+	timers []*fakeTimer
+	// creation order of the timers, so that timers due at the same time fire in the order they were created
+	sequence int
+}
+
+type fakeTimer struct {
+	fake     *Fake
+	due      int64
+	sequence int
+	f        func()
+}
+
+// NewFake returns a fake clock whose current time is now, in milliseconds epoch
+func NewFake(now int64) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the current time of the fake clock in milliseconds epoch
+func (fake *Fake) Now() int64 {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return fake.now
+}
+
+// AfterFunc calls f once the fake clock is advanced past d from now. Unlike the system clock, f is called
+// by Advance itself rather than in its own goroutine.
+func (fake *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	fake.sequence++
+	timer := &fakeTimer{
+		fake:     fake,
+		due:      fake.now + int64(d/time.Millisecond),
+		sequence: fake.sequence,
+		f:        f,
+	}
+	fake.timers = append(fake.timers, timer)
+	return timer
+}
+
+// Stop removes the timer from the fake clock
+func (timer *fakeTimer) Stop() bool {
+	timer.fake.mutex.Lock()
+	defer timer.fake.mutex.Unlock()
+	return timer.fake.remove(timer)
+}
+
+// Advance moves the fake clock forward to the given time in milliseconds epoch, firing every timer due by then
+// in order. The timers those functions start are fired too if they are due by then. The clock never moves backwards.
+func (fake *Fake) Advance(to int64) {
+	for fake.Step(to) {
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	if to > fake.now {
+		fake.now = to
+	}
+}
+
+// Step fires the next timer due by the given time in milliseconds epoch, with the clock at its due time
+// while its function is called. It returns false, without moving the clock, if no timer is due by then.
+func (fake *Fake) Step(to int64) bool {
+	fake.mutex.Lock()
+	timer := fake.next()
+	if timer == nil || timer.due > to {
+		fake.mutex.Unlock()
+		return false
+	}
+	fake.remove(timer)
+	if timer.due > fake.now {
+		fake.now = timer.due
+	}
+	fake.mutex.Unlock()
+
+	timer.f()
+	return true
+}
+
+// next returns the timer which is due first, and must be called with the mutex held
+func (fake *Fake) next() *fakeTimer {
+	var first *fakeTimer
+	for _, timer := range fake.timers {
+		if first == nil || timer.due < first.due || (timer.due == first.due && timer.sequence < first.sequence) {
+			first = timer
+		}
+	}
+	return first
+}
+
+// remove removes a timer which has not fired yet, and must be called with the mutex held
+func (fake *Fake) remove(timer *fakeTimer) bool {
+	for i, t := range fake.timers {
+		if t == timer {
+			fake.timers = append(fake.timers[:i], fake.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package clock
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFakeAdvance(t *testing.T) {
+	fake := NewFake(1000)
+
+	var fired []int64
+	record := func() {
+		fired = append(fired, fake.Now())
+	}
+	fake.AfterFunc(3*time.Second, record)
+	fake.AfterFunc(time.Second, record)
+	stopped := fake.AfterFunc(2*time.Second, record)
+	// repeats every one and a half seconds, like a ticker
+	var tick func()
+	tick = func() {
+		record()
+		fake.AfterFunc(1500*time.Millisecond, tick)
+	}
+	fake.AfterFunc(1500*time.Millisecond, tick)
+
+	if !stopped.Stop() {
+		t.Error("expected the timer to stop")
+	}
+	if stopped.Stop() {
+		t.Error("expected a stopped timer not to stop again")
+	}
+
+	fake.Advance(4500)
+	if expected := []int64{2000, 2500, 4000, 4000}; !reflect.DeepEqual(fired, expected) {
+		t.Errorf("expected timers to fire at %v, but got %v", expected, fired)
+	}
+	if fake.Now() != 4500 {
+		t.Errorf("expected the clock to be at 4500, but got %d", fake.Now())
+	}
+
+	// the clock never moves backwards
+	fake.Advance(3000)
+	if fake.Now() != 4500 {
+		t.Errorf("expected the clock to stay at 4500, but got %d", fake.Now())
+	}
+
+	if fake.Step(5000) {
+		t.Error("expected no timer to be due by 5000")
+	}
+	if !fake.Step(5500) || fake.Now() != 5500 {
+		t.Errorf("expected the ticker to fire at 5500, but the clock is at %d", fake.Now())
+	}
+}
@@ -0,0 +1,167 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package direction infers which way a tag is moving through the store entrance from the timestamps
+// of its whole location history, rather than only the two most recent locations.
+package direction
+
+import (
+	"fmt"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/sensor"
+	"sort"
+)
+
+// Direction is the movement of a tag relative to the store exits
+type Direction string
+
+const (
+	// Exit is a tag which recently moved from inside the store to an exit
+	Exit Direction = "exit"
+	// ReEntry is a tag which recently moved from an exit back inside the store
+	ReEntry Direction = "re_entry"
+	// NearDoor is a tag at an exit which did not recently walk up to it, such as an item on display next
+	// to the door, or one which keeps moving between the exit and the sales floor
+	NearDoor Direction = "near_door"
+	// Inside is a tag inside the store which did not recently come in through an exit
+	Inside Direction = "inside"
+	// Unknown is a tag currently read at a sensor which is not registered
+	Unknown Direction = "unknown"
+
+	// AmbiguousConfidence is the confidence of an inference which could be explained by more than one direction
+	AmbiguousConfidence = 0.5
+	// flappingTransitions is how many moves between the exit and the sales floor within the maximum
+	// transition time turn an exit into an item sitting near the door
+	flappingTransitions = 3
+)
+
+// Directions lists every direction, such as for validating rule conditions
+var Directions = []Direction{Exit, ReEntry, NearDoor, Inside, Unknown}
+
+// Step is a single location of a tag's history, with the sensor it resolves to (nil if unknown)
+type Step struct {
+	Location string
+	Sensor   *sensor.RSP
+	// Time the tag arrived at the location in milliseconds epoch
+	Timestamp int64
+}
+
+// Inference is the direction a tag is moving in, and how confident the inference is
+type Inference struct {
+	Direction Direction `json:"direction"`
+	// Between 0 and 1, lower when the history could also be explained by another direction
+	Confidence float64 `json:"confidence"`
+	// Milliseconds since the tag moved between the inside of the store and an exit, 0 if it never did
+	SinceTransition int64 `json:"since_transition"`
+	// Number of moves between the inside of the store and an exit within the maximum transition time
+	Transitions int    `json:"transitions"`
+	Reason      string `json:"reason"`
+}
+
+type zone int
+
+const (
+	zoneUnknown zone = iota
+	zoneInside
+	zoneExit
+)
+
+func zoneOf(step Step) zone {
+	switch {
+	case step.Sensor == nil:
+		return zoneUnknown
+	case step.Sensor.IsExitSensor():
+		return zoneExit
+	default:
+		return zoneInside
+	}
+}
+
+// run is a number of consecutive steps in the same zone
+type run struct {
+	zone zone
+	// time the tag arrived in the zone
+	arrived int64
+}
+
+// Infer works out the direction of a tag at now from its location history. Steps may be in any order;
+// they are sorted by timestamp. A move into or out of the store only counts if it happened within
+// maxTransition milliseconds of now, otherwise the history is considered stale.
+func Infer(steps []Step, now int64, maxTransition int64) Inference {
+	if len(steps) == 0 {
+		return Inference{Direction: Unknown, Reason: "tag has no location history"}
+	}
+
+	sorted := append([]Step(nil), steps...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp > sorted[j].Timestamp
+	})
+
+	// runs are ordered from the current zone to the oldest
+	var runs []run
+	for _, step := range sorted {
+		z := zoneOf(step)
+		if len(runs) > 0 && runs[len(runs)-1].zone == z {
+			runs[len(runs)-1].arrived = step.Timestamp
+			continue
+		}
+		runs = append(runs, run{zone: z, arrived: step.Timestamp})
+	}
+
+	current := runs[0]
+	if current.zone == zoneUnknown {
+		return Inference{Direction: Unknown, Reason: fmt.Sprintf("sensor of location %s is unknown", sorted[0].Location)}
+	}
+
+	inference := Inference{Confidence: 1}
+	if len(runs) > 1 {
+		inference.SinceTransition = now - current.arrived
+	}
+	for i := 1; i < len(runs) && now-runs[i-1].arrived <= maxTransition; i++ {
+		inference.Transitions++
+	}
+	stale := len(runs) == 1 || inference.SinceTransition > maxTransition
+
+	if current.zone == zoneInside {
+		if stale || runs[1].zone != zoneExit {
+			inference.Direction = Inside
+			inference.Reason = "not recently at an exit"
+			return inference
+		}
+		inference.Direction = ReEntry
+		inference.Reason = fmt.Sprintf("moved inside from an exit %d ms ago", inference.SinceTransition)
+		if inference.Transitions > 1 {
+			inference.Confidence = AmbiguousConfidence
+			inference.Reason += fmt.Sprintf(", after %d moves between the exit and the store", inference.Transitions)
+		}
+		return inference
+	}
+
+	switch {
+	case len(runs) == 1:
+		inference.Direction = NearDoor
+		inference.Reason = "never read inside the store"
+	case stale:
+		inference.Direction = NearDoor
+		inference.Reason = fmt.Sprintf("at the exit for %d ms, longer than the maximum transition time of %d ms",
+			inference.SinceTransition, maxTransition)
+	case inference.Transitions >= flappingTransitions:
+		inference.Direction = NearDoor
+		inference.Confidence = AmbiguousConfidence
+		inference.Reason = fmt.Sprintf("moved between the exit and the store %d times within %d ms", inference.Transitions, maxTransition)
+	case runs[1].zone == zoneUnknown:
+		inference.Direction = Exit
+		inference.Confidence = AmbiguousConfidence
+		inference.Reason = fmt.Sprintf("moved to the exit %d ms ago from an unknown sensor", inference.SinceTransition)
+	case inference.Transitions > 1:
+		inference.Direction = Exit
+		inference.Confidence = AmbiguousConfidence
+		inference.Reason = fmt.Sprintf("moved to the exit %d ms ago, shortly after coming in from it", inference.SinceTransition)
+	default:
+		inference.Direction = Exit
+		inference.Reason = fmt.Sprintf("moved to the exit from inside the store %d ms ago", inference.SinceTransition)
+	}
+	return inference
+}
@@ -0,0 +1,128 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package direction
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/sensor"
+	"testing"
+)
+
+func TestInfer(t *testing.T) {
+	exit := sensor.NewRSP("RSP-150000")
+	exit.Personality = sensor.Exit
+	floor := sensor.NewRSP("RSP-150001")
+
+	const (
+		now           = 1000000
+		maxTransition = 30000
+	)
+	at := func(rsp *sensor.RSP, timestamp int64) Step {
+		location := "unknown-0"
+		if rsp != nil {
+			location = rsp.AntennaAlias(0)
+		}
+		return Step{Location: location, Sensor: rsp, Timestamp: timestamp}
+	}
+
+	tests := []struct {
+		name       string
+		steps      []Step
+		direction  Direction
+		confidence float64
+	}{
+		{
+			name:      "no history",
+			direction: Unknown,
+		},
+		{
+			name:      "unknown sensor",
+			steps:     []Step{at(nil, now-1000), at(floor, now-5000)},
+			direction: Unknown,
+		},
+		{
+			name:       "walked out",
+			steps:      []Step{at(exit, now-1000), at(floor, now-600000)},
+			direction:  Exit,
+			confidence: 1,
+		},
+		{
+			name:       "walked out between exit antennas",
+			steps:      []Step{at(exit, now-1000), at(exit, now-5000), at(floor, now-600000)},
+			direction:  Exit,
+			confidence: 1,
+		},
+		{
+			name:       "reversed history",
+			steps:      []Step{at(floor, now-600000), at(exit, now-1000)},
+			direction:  Exit,
+			confidence: 1,
+		},
+		{
+			name:       "reversed history back inside",
+			steps:      []Step{at(exit, now-5000), at(floor, now-1000)},
+			direction:  ReEntry,
+			confidence: 1,
+		},
+		{
+			name:       "stale move to the exit",
+			steps:      []Step{at(exit, now-60000), at(floor, now-600000)},
+			direction:  NearDoor,
+			confidence: 1,
+		},
+		{
+			name:       "only ever at the exit",
+			steps:      []Step{at(exit, now-1000)},
+			direction:  NearDoor,
+			confidence: 1,
+		},
+		{
+			name:       "from an unknown sensor",
+			steps:      []Step{at(exit, now-1000), at(nil, now-600000)},
+			direction:  Exit,
+			confidence: AmbiguousConfidence,
+		},
+		{
+			name:       "came in and went back out",
+			steps:      []Step{at(exit, now-1000), at(floor, now-10000), at(exit, now-600000)},
+			direction:  Exit,
+			confidence: AmbiguousConfidence,
+		},
+		{
+			name:       "moving back and forth at the door",
+			steps:      []Step{at(exit, now-1000), at(floor, now-5000), at(exit, now-10000), at(floor, now-15000)},
+			direction:  NearDoor,
+			confidence: AmbiguousConfidence,
+		},
+		{
+			name:       "came back in",
+			steps:      []Step{at(floor, now-1000), at(exit, now-600000)},
+			direction:  ReEntry,
+			confidence: 1,
+		},
+		{
+			name:       "back in a while ago",
+			steps:      []Step{at(floor, now-60000), at(exit, now-600000)},
+			direction:  Inside,
+			confidence: 1,
+		},
+		{
+			name:       "never at an exit",
+			steps:      []Step{at(floor, now-1000)},
+			direction:  Inside,
+			confidence: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inference := Infer(test.steps, now, maxTransition)
+			if inference.Direction != test.direction || inference.Confidence != test.confidence {
+				t.Errorf("Expected %s with confidence %v, but got %+v", test.direction, test.confidence, inference)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package expression is a small boolean expression language used to write custom trigger conditions,
+// such as:
+//   tag.product_id in watchlist && from.personality == "FITTING_ROOM"
+//
+// Expressions support string, number, boolean and nil literals, list literals ([1, 2]), field access
+// by json name (tag.product_id), indexing (history[1]), the comparison operators == != < <= > >= and in,
+// and the logical operators && || !. Accessing a field of nil, or indexing past the end of a list, is nil.
+package expression
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Program is a compiled expression, which can be evaluated any number of times
+type Program struct {
+	source string
+	root   node
+}
+
+// Compile parses an expression. If variables is not nil, every variable the expression
+// uses must be one of them.
+func Compile(source string, variables []string) (*Program, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.unexpected("expected the end of the expression")
+	}
+
+	if variables != nil {
+		allowed := make(map[string]bool)
+		for _, name := range variables {
+			allowed[name] = true
+		}
+		for _, name := range root.variables(nil) {
+			if !allowed[name] {
+				sorted := append([]string(nil), variables...)
+				sort.Strings(sorted)
+				return nil, fmt.Errorf("unknown variable %s, must be one of: %s", name, strings.Join(sorted, ", "))
+			}
+		}
+	}
+
+	return &Program{source: source, root: root}, nil
+}
+
+// String returns the source of the expression
+func (program *Program) String() string {
+	return program.source
+}
+
+// Eval evaluates the expression against the variables in env
+func (program *Program) Eval(env map[string]interface{}) (interface{}, error) {
+	return program.root.eval(env)
+}
+
+// EvalBool evaluates the expression, which must result in a boolean
+func (program *Program) EvalBool(env map[string]interface{}) (bool, error) {
+	value, err := program.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression must result in true or false, but got %v", value)
+	}
+	return result, nil
+}
+
+type node interface {
+	eval(env map[string]interface{}) (interface{}, error)
+	// variables appends the name of every variable used by the node
+	variables(names []string) []string
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(env map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *literalNode) variables(names []string) []string {
+	return names
+}
+
+type variableNode struct {
+	name string
+}
+
+func (n *variableNode) eval(env map[string]interface{}) (interface{}, error) {
+	value, ok := env[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown variable %s", n.name)
+	}
+	return value, nil
+}
+
+func (n *variableNode) variables(names []string) []string {
+	return append(names, n.name)
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) eval(env map[string]interface{}) (interface{}, error) {
+	list := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		value, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
+
+func (n *listNode) variables(names []string) []string {
+	for _, item := range n.items {
+		names = item.variables(names)
+	}
+	return names
+}
+
+type memberNode struct {
+	object node
+	field  string
+}
+
+func (n *memberNode) eval(env map[string]interface{}) (interface{}, error) {
+	object, err := n.object.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	value := indirect(reflect.ValueOf(object))
+	if !value.IsValid() {
+		return nil, nil
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		valueType := value.Type()
+		for i := 0; i < valueType.NumField(); i++ {
+			field := valueType.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == n.field || (name == "" && field.Name == n.field) {
+				return value.Field(i).Interface(), nil
+			}
+		}
+		return nil, fmt.Errorf("unknown field %s", n.field)
+
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			break
+		}
+		entry := value.MapIndex(reflect.ValueOf(n.field).Convert(value.Type().Key()))
+		if !entry.IsValid() {
+			return nil, nil
+		}
+		return entry.Interface(), nil
+	}
+
+	return nil, fmt.Errorf("unable to access field %s of %v", n.field, object)
+}
+
+func (n *memberNode) variables(names []string) []string {
+	return n.object.variables(names)
+}
+
+type indexNode struct {
+	object node
+	index  node
+}
+
+func (n *indexNode) eval(env map[string]interface{}) (interface{}, error) {
+	object, err := n.object.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	value := indirect(reflect.ValueOf(object))
+	if !value.IsValid() {
+		return nil, nil
+	}
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return nil, fmt.Errorf("unable to index %v", object)
+	}
+
+	number, ok := normalize(index).(float64)
+	if !ok || number != float64(int(number)) {
+		return nil, fmt.Errorf("index must be a whole number, but got %v", index)
+	}
+	i := int(number)
+	if i < 0 || i >= value.Len() {
+		return nil, nil
+	}
+	return value.Index(i).Interface(), nil
+}
+
+func (n *indexNode) variables(names []string) []string {
+	return n.index.variables(n.object.variables(names))
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(env map[string]interface{}) (interface{}, error) {
+	value, err := evalBool(n.operand, env)
+	if err != nil {
+		return nil, err
+	}
+	return !value, nil
+}
+
+func (n *notNode) variables(names []string) []string {
+	return n.operand.variables(names)
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) eval(env map[string]interface{}) (interface{}, error) {
+	left, err := evalBool(n.left, env)
+	if err != nil {
+		return nil, err
+	}
+	if (n.op == "&&" && !left) || (n.op == "||" && left) {
+		return left, nil
+	}
+	return evalBool(n.right, env)
+}
+
+func (n *logicalNode) variables(names []string) []string {
+	return n.right.variables(n.left.variables(names))
+}
+
+type comparisonNode struct {
+	op          string
+	left, right node
+}
+
+func (n *comparisonNode) eval(env map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		return contains(right, left)
+	}
+
+	left, right = normalize(left), normalize(right)
+	if leftNumber, ok := left.(float64); ok {
+		if rightNumber, ok := right.(float64); ok {
+			return compare(n.op, leftNumber < rightNumber, leftNumber == rightNumber), nil
+		}
+	}
+	if leftString, ok := left.(string); ok {
+		if rightString, ok := right.(string); ok {
+			return compare(n.op, leftString < rightString, leftString == rightString), nil
+		}
+	}
+	return nil, fmt.Errorf("unable to compare %v %s %v", left, n.op, right)
+}
+
+func (n *comparisonNode) variables(names []string) []string {
+	return n.right.variables(n.left.variables(names))
+}
+
+func compare(op string, less bool, equal bool) bool {
+	switch op {
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	default:
+		return !less
+	}
+}
+
+// evalBool evaluates a node which must result in a boolean. nil is treated as false.
+func evalBool(n node, env map[string]interface{}) (bool, error) {
+	value, err := n.eval(env)
+	if err != nil {
+		return false, err
+	}
+	switch result := normalize(value).(type) {
+	case bool:
+		return result, nil
+	case nil:
+		return false, nil
+	default:
+		return false, fmt.Errorf("expected true or false, but got %v", value)
+	}
+}
+
+// contains returns true if value is an element of a list, a key of a map, or a substring of a string
+func contains(collection interface{}, value interface{}) (bool, error) {
+	list := indirect(reflect.ValueOf(collection))
+	if !list.IsValid() {
+		return false, nil
+	}
+
+	switch list.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < list.Len(); i++ {
+			if equal(list.Index(i).Interface(), value) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case reflect.Map:
+		for _, key := range list.MapKeys() {
+			if equal(key.Interface(), value) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case reflect.String:
+		if s, ok := normalize(value).(string); ok {
+			return strings.Contains(list.String(), s), nil
+		}
+	}
+
+	return false, fmt.Errorf("unable to check if %v is in %v", value, collection)
+}
+
+func equal(left interface{}, right interface{}) bool {
+	left, right = normalize(left), normalize(right)
+	switch left.(type) {
+	case nil, bool, float64, string:
+		return left == right
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// normalize turns every number into a float64 and every string type (such as sensor.Personality)
+// into a string, so that values from the models can be compared to literals
+func normalize(value interface{}) interface{} {
+	v := indirect(reflect.ValueOf(value))
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return v.Interface()
+}
+
+// indirect follows pointers and interfaces, returning an invalid value for nil
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
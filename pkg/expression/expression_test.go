@@ -0,0 +1,130 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package expression
+
+import (
+	"testing"
+)
+
+type personality string
+
+type rsp struct {
+	DeviceId    string      `json:"device_id"`
+	Personality personality `json:"personality"`
+}
+
+type history struct {
+	Location  string `json:"location"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type tag struct {
+	Epc             string    `json:"epc"`
+	ProductID       string    `json:"product_id"`
+	Confidence      float64   `json:"confidence,omitempty"`
+	LocationHistory []history `json:"location_history"`
+}
+
+func testEnv() map[string]interface{} {
+	var unknown *rsp
+	return map[string]interface{}{
+		"tag": &tag{
+			Epc:        "3014AB",
+			ProductID:  "012345",
+			Confidence: 0.75,
+			LocationHistory: []history{
+				{Location: "RSP-150000-0", Timestamp: 2000},
+				{Location: "RSP-150001-0", Timestamp: 1000},
+			},
+		},
+		"from":      &rsp{DeviceId: "RSP-150001", Personality: "FITTING_ROOM"},
+		"to":        &rsp{DeviceId: "RSP-150000", Personality: "EXIT"},
+		"unknown":   unknown,
+		"watchlist": []string{"012345", "987654"},
+	}
+}
+
+func TestEvalBool(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{`tag.product_id in watchlist && from.personality == "FITTING_ROOM"`, true},
+		{`tag.product_id in watchlist && from.personality == 'EXIT'`, false},
+		{`!(tag.epc in ["3014AA", "3014AB"])`, false},
+		{`tag.confidence >= 0.5 && tag.confidence < 1`, true},
+		{`tag.location_history[0].timestamp > tag.location_history[1].timestamp`, true},
+		{`tag.location_history[5] == nil`, true},
+		{`unknown.personality == "EXIT"`, false},
+		{`unknown == nil || unknown.device_id == "x"`, true},
+		{`"RSP-1500" in to.device_id`, true},
+		{`to.personality != from.personality && (false || true)`, true},
+	}
+
+	env := testEnv()
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			program, err := Compile(test.expression, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := program.EvalBool(env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %v, but got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	variables := []string{"tag", "from", "to", "watchlist"}
+	tests := []string{
+		``,
+		`tag.product_id ==`,
+		`tag.product_id in watchlist &&`,
+		`(tag.epc == "a"`,
+		`tag.epc == "unterminated`,
+		`tag. == "a"`,
+		`tag.epc = "a"`,
+		`tag.epc == "a" "b"`,
+		`tags.epc == "a"`,
+	}
+
+	for _, source := range tests {
+		t.Run(source, func(t *testing.T) {
+			if _, err := Compile(source, variables); err == nil {
+				t.Error("Expected a compile error")
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []string{
+		`tag.epc`,
+		`tag.missing == "a"`,
+		`tag.epc < 5`,
+		`tag.epc && true`,
+		`tag.location_history["a"] == nil`,
+	}
+
+	env := testEnv()
+	for _, source := range tests {
+		t.Run(source, func(t *testing.T) {
+			program, err := Compile(source, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := program.EvalBool(env); err == nil {
+				t.Error("Expected an evaluation error")
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package jsonrpc
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ReasonDecode is the reason counted when a message is not valid json
+	ReasonDecode = "decode"
+	// ReasonInvalid is the reason counted when Validate fails without a ValidationError
+	ReasonInvalid = "invalid"
+	// ReasonMissingField is used when a required field is missing or empty
+	ReasonMissingField = "missing_field"
+	// ReasonInvalidFormat is used when a field does not have the expected format
+	ReasonInvalidFormat = "invalid_format"
+	// ReasonInvalidValue is used when a field is not one of the allowed values, or is out of range
+	ReasonInvalidValue = "invalid_value"
+)
+
+// ValidationError is returned by Validate when a message is malformed. Decode counts
+// every failure by its Reason.
+type ValidationError struct {
+	Reason string
+	// Path to the offending field, such as data[2].epc
+	Field   string
+	Message string
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Field, err.Message)
+}
+
+// NewValidationError returns a ValidationError for the field
+func NewValidationError(reason string, field string, format string, args ...interface{}) error {
+	return &ValidationError{
+		Reason:  reason,
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// FailureReason returns the reason a message failed to decode or validate
+func FailureReason(err error) string {
+	if validationErr, ok := errors.Cause(err).(*ValidationError); ok {
+		return validationErr.Reason
+	}
+	return ReasonInvalid
+}
@@ -14,31 +14,46 @@ import (
 	"strings"
 )
 
-func errorHandler(message string, err error, errorGauge *metrics.Gauge) {
+func errorHandler(message string, reason string, err error, errorGauge *metrics.Gauge) {
 	if err != nil {
 		if errorGauge != nil {
 			(*errorGauge).Update(1)
 		}
+		metrics.GetOrRegisterCounter("loss-prevention-service.Decode.Failed."+reason, nil).Inc(1)
 		logrus.WithFields(logrus.Fields{
 			"Method": "jsonrpc.Decode",
+			"Reason": reason,
 			"Error":  fmt.Sprintf("%+v", err),
 		}).Error(message)
 	}
 }
 
+// Decode unmarshals and validates a message. Every failure is counted per reason, see FailureReason.
 func Decode(value string, js Message, errorGauge *metrics.Gauge) error {
-	decoder := json.NewDecoder(strings.NewReader(value))
-	decoder.UseNumber()
-
-	if err := decoder.Decode(js); err != nil {
-		errorHandler("error decoding jsonrpc messaage", err, errorGauge)
+	if err := unmarshal(value, js); err != nil {
+		errorHandler("error decoding jsonrpc messaage", ReasonDecode, err, errorGauge)
 		return err
 	}
 
 	if err := js.Validate(); err != nil {
-		errorHandler("error validating jsonrpc messaage", err, errorGauge)
+		errorHandler("error validating jsonrpc messaage", FailureReason(err), err, errorGauge)
 		return err
 	}
 
 	return nil
 }
+
+// DecodeWithoutMetrics unmarshals and validates a message like Decode, but without counting or logging failures.
+// It is meant for messages posted to the API to try things out, which should not count against the service.
+func DecodeWithoutMetrics(value string, js Message) error {
+	if err := unmarshal(value, js); err != nil {
+		return err
+	}
+	return js.Validate()
+}
+
+func unmarshal(value string, js Message) error {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	return decoder.Decode(js)
+}
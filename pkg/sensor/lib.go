@@ -16,6 +16,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,11 +28,16 @@ const (
 
 var (
 	sensors = make(map[string]*RSP)
+	// sensors are updated by the EdgeX pipeline and the sensor queries, and read by the HTTP handlers
+	sensorsMutex sync.RWMutex
 )
 
 // FindByAntennaAlias is a backwards lookup of an alias to the sensor (RSP) it belongs to
 // Note that if more than one sensor has the same alias, it will just return the first match
 func FindByAntennaAlias(alias string) *RSP {
+	sensorsMutex.RLock()
+	defer sensorsMutex.RUnlock()
+
 	for _, rsp := range sensors {
 		for _, a := range rsp.Aliases {
 			if a == alias {
@@ -48,7 +54,9 @@ func GetOrQueryRSPInfo(deviceId string) (*RSP, error) {
 	var err error
 	var info *jsonrpc.SensorBasicInfo
 
+	sensorsMutex.RLock()
 	rsp, ok := sensors[deviceId]
+	sensorsMutex.RUnlock()
 	if !ok {
 		rsp = NewRSP(deviceId)
 
@@ -62,13 +70,16 @@ func GetOrQueryRSPInfo(deviceId string) (*RSP, error) {
 			rsp.Aliases = info.Aliases
 			rsp.FacilityId = info.FacilityId
 		}
-		sensors[deviceId] = rsp
+		UpdateRSP(rsp)
 	}
 
 	return rsp, err
 }
 
 func UpdateRSP(rsp *RSP) {
+	sensorsMutex.Lock()
+	defer sensorsMutex.Unlock()
+
 	sensors[rsp.DeviceId] = rsp
 }
 
@@ -118,7 +129,7 @@ func ForceRefreshSensorInfo(deviceId string) {
 	rsp.Personality = Personality(info.Personality)
 	rsp.Aliases = info.Aliases
 	rsp.FacilityId = info.FacilityId
-	sensors[deviceId] = rsp
+	UpdateRSP(rsp)
 	logrus.Infof("got rsp info: %+v", rsp)
 }
 
@@ -0,0 +1,39 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package sensor
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestFindByAntennaAlias(t *testing.T) {
+	UpdateRSP(NewRSP("RSP-150000"))
+
+	// lookups from the HTTP handlers run alongside updates from the EdgeX pipeline
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			UpdateRSP(NewRSP(fmt.Sprintf("RSP-%06d", i)))
+		}(i)
+		go func() {
+			defer wg.Done()
+			FindByAntennaAlias("RSP-150000-0")
+		}()
+	}
+	wg.Wait()
+
+	if rsp := FindByAntennaAlias("RSP-150000-0"); rsp == nil || rsp.DeviceId != "RSP-150000" {
+		t.Errorf("expected to find RSP-150000, but got %+v", rsp)
+	}
+	if rsp := FindByAntennaAlias("RSP-999999-0"); rsp != nil {
+		t.Errorf("expected an unknown alias not to match a sensor, but got %+v", rsp)
+	}
+}
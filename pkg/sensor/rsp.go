@@ -75,3 +75,8 @@ func (rsp *RSP) IsExitSensor() bool {
 func (rsp *RSP) IsPOSSensor() bool {
 	return rsp.Personality == POS
 }
+
+// IsFittingRoomSensor returns true if this RSP has the FITTING_ROOM personality
+func (rsp *RSP) IsFittingRoomSensor() bool {
+	return rsp.Personality == FittingRoom
+}
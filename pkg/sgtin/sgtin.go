@@ -0,0 +1,171 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package sgtin decodes SGTIN-96 and SGTIN-198 EPCs, as defined by the GS1 EPC Tag Data Standard,
+// into the GTIN of the trade item and its serial number.
+package sgtin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	Scheme96  = "sgtin-96"
+	Scheme198 = "sgtin-198"
+
+	header96  = 0x30
+	header198 = 0x36
+
+	// number of bits of the company prefix and item reference together
+	partitionBits = 44
+	// number of bits of each character of an SGTIN-198 serial
+	serialCharBits = 7
+)
+
+// partition is a row of the SGTIN partition table
+type partition struct {
+	companyPrefixBits, companyPrefixDigits int
+	itemReferenceBits, itemReferenceDigits int
+}
+
+var partitions = []partition{
+	{40, 12, 4, 1},
+	{37, 11, 7, 2},
+	{34, 10, 10, 3},
+	{30, 9, 14, 4},
+	{27, 8, 17, 5},
+	{24, 7, 20, 6},
+	{20, 6, 24, 7},
+}
+
+// SGTIN is a decoded Serialized Global Trade Item Number
+type SGTIN struct {
+	// Either Scheme96 or Scheme198
+	Scheme string `json:"scheme"`
+	Filter int    `json:"filter"`
+	// GS1 company prefix, with leading zeros
+	CompanyPrefix string `json:"company_prefix"`
+	// Item reference including the indicator digit, with leading zeros
+	ItemReference string `json:"item_reference"`
+	// GTIN-14 of the trade item, including the check digit
+	GTIN   string `json:"gtin"`
+	Serial string `json:"serial"`
+}
+
+// URI returns the pure identity EPC URI, such as urn:epc:id:sgtin:0614141.812345.6789
+func (sgtin *SGTIN) URI() string {
+	return fmt.Sprintf("urn:epc:id:sgtin:%s.%s.%s", sgtin.CompanyPrefix, sgtin.ItemReference, sgtin.Serial)
+}
+
+// Decode decodes an EPC in hex, returning an error if it is not a valid SGTIN-96 or SGTIN-198
+func Decode(epc string) (*SGTIN, error) {
+	data, err := hex.DecodeString(epc)
+	if err != nil {
+		return nil, fmt.Errorf("epc %s is not hex: %v", epc, err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("epc is empty")
+	}
+
+	var scheme string
+	var serialBits int
+	switch data[0] {
+	case header96:
+		scheme, serialBits = Scheme96, 38
+	case header198:
+		scheme, serialBits = Scheme198, 140
+	default:
+		return nil, fmt.Errorf("epc %s is not an sgtin, header is %#x", epc, data[0])
+	}
+	if bits := 8 + 3 + 3 + partitionBits + serialBits; len(data)*8 < bits {
+		return nil, fmt.Errorf("epc %s is too short for %s, expected %d bits but got %d", epc, scheme, bits, len(data)*8)
+	}
+
+	reader := &bitReader{data: data, next: 8}
+	result := &SGTIN{Scheme: scheme, Filter: int(reader.read(3))}
+
+	index := int(reader.read(3))
+	if index >= len(partitions) {
+		return nil, fmt.Errorf("epc %s has invalid partition %d", epc, index)
+	}
+	p := partitions[index]
+
+	if result.CompanyPrefix, err = decimal(reader.read(p.companyPrefixBits), p.companyPrefixDigits); err != nil {
+		return nil, fmt.Errorf("epc %s has invalid company prefix: %v", epc, err)
+	}
+	if result.ItemReference, err = decimal(reader.read(p.itemReferenceBits), p.itemReferenceDigits); err != nil {
+		return nil, fmt.Errorf("epc %s has invalid item reference: %v", epc, err)
+	}
+
+	if scheme == Scheme96 {
+		result.Serial = strconv.FormatUint(reader.read(serialBits), 10)
+	} else if result.Serial, err = readSerial(reader, serialBits); err != nil {
+		return nil, fmt.Errorf("epc %s has invalid serial: %v", epc, err)
+	}
+
+	// the indicator digit moves to the front of the GTIN
+	digits := result.ItemReference[:1] + result.CompanyPrefix + result.ItemReference[1:]
+	result.GTIN = digits + strconv.Itoa(CheckDigit(digits))
+	return result, nil
+}
+
+// CheckDigit returns the GS1 check digit of a string of digits
+func CheckDigit(digits string) int {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := int(digits[i] - '0')
+		// weights alternate 3, 1, 3, ... starting from the rightmost digit
+		if (len(digits)-1-i)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return (10 - sum%10) % 10
+}
+
+// decimal formats a value with leading zeros, returning an error if it has more than the given number of digits
+func decimal(value uint64, digits int) (string, error) {
+	s := fmt.Sprintf("%0*d", digits, value)
+	if len(s) > digits {
+		return "", fmt.Errorf("%d has more than %d digits", value, digits)
+	}
+	return s, nil
+}
+
+// readSerial reads an SGTIN-198 serial, which is made of 7 bit characters padded with zeros
+func readSerial(reader *bitReader, bits int) (string, error) {
+	var serial strings.Builder
+	for i := 0; i < bits/serialCharBits; i++ {
+		char := byte(reader.read(serialCharBits))
+		if char == 0 {
+			break
+		}
+		if char < 0x21 || char > 0x7A {
+			return "", fmt.Errorf("invalid character %#x", char)
+		}
+		serial.WriteByte(char)
+	}
+	return serial.String(), nil
+}
+
+// bitReader reads big endian values of up to 64 bits at any bit offset
+type bitReader struct {
+	data []byte
+	next int
+}
+
+func (reader *bitReader) read(bits int) uint64 {
+	var value uint64
+	for i := 0; i < bits; i++ {
+		bit := (reader.data[reader.next/8] >> uint(7-reader.next%8)) & 1
+		value = value<<1 | uint64(bit)
+		reader.next++
+	}
+	return value
+}
@@ -0,0 +1,125 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package sgtin
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// encode198 builds an SGTIN-198 with partition 5 (7 digit company prefix)
+func encode198(filter uint64, companyPrefix uint64, itemReference uint64, serial string) string {
+	bits := &strings.Builder{}
+	write := func(value uint64, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits.WriteByte(byte('0' + (value>>uint(i))&1))
+		}
+	}
+	write(header198, 8)
+	write(filter, 3)
+	write(5, 3)
+	write(companyPrefix, 24)
+	write(itemReference, 20)
+	for i := 0; i < 20; i++ {
+		var char uint64
+		if i < len(serial) {
+			char = uint64(serial[i])
+		}
+		write(char, 7)
+	}
+	write(0, 2)
+
+	data := make([]byte, bits.Len()/8)
+	for i := range data {
+		for _, bit := range bits.String()[i*8 : i*8+8] {
+			data[i] = data[i]<<1 | byte(bit-'0')
+		}
+	}
+	return strings.ToUpper(hex.EncodeToString(data))
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		epc  string
+		want SGTIN
+	}{
+		{
+			"sgtin-96",
+			"3074257BF7194E4000001A85",
+			SGTIN{Scheme: Scheme96, Filter: 3, CompanyPrefix: "0614141", ItemReference: "812345", GTIN: "80614141123458", Serial: "6789"},
+		},
+		{
+			"sgtin-198",
+			encode198(3, 614141, 812345, "32a/b"),
+			SGTIN{Scheme: Scheme198, Filter: 3, CompanyPrefix: "0614141", ItemReference: "812345", GTIN: "80614141123458", Serial: "32a/b"},
+		},
+		{
+			"lower case",
+			"3074257bf7194e4000001a85",
+			SGTIN{Scheme: Scheme96, Filter: 3, CompanyPrefix: "0614141", ItemReference: "812345", GTIN: "80614141123458", Serial: "6789"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := Decode(test.epc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *result != test.want {
+				t.Errorf("expected %+v, but got %+v", test.want, *result)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		epc  string
+	}{
+		{"empty", ""},
+		{"not hex", "30XX257BF7194E4000001A85"},
+		{"not an sgtin", "E2801160600002054CC2096F"},
+		{"too short", "3074257BF7194E40"},
+		{"invalid partition", "307C257BF7194E4000001A85"},
+		{"invalid serial character", encode198(3, 614141, 812345, "a b")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result, err := Decode(test.epc); err == nil {
+				t.Errorf("expected an error, but got %+v", result)
+			}
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	result, err := Decode("3074257BF7194E4000001A85")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri := result.URI(); uri != "urn:epc:id:sgtin:0614141.812345.6789" {
+		t.Errorf("expected urn:epc:id:sgtin:0614141.812345.6789, but got %s", uri)
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	tests := map[string]int{
+		"8061414112345": 8,
+		"03600029145":   2,
+		"0000000000000": 0,
+	}
+	for digits, want := range tests {
+		if got := CheckDigit(digits); got != want {
+			t.Errorf("expected check digit of %s to be %d, but got %d", digits, want, got)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package timeofday handles 24 hour HH:MM times of day, and the windows between two of them
+package timeofday
+
+import (
+	"fmt"
+	"time"
+)
+
+// Layout is the layout of HH:MM times of day
+const Layout = "15:04"
+
+// Window is the time of day from Start (inclusive) until End (exclusive), both in minutes of the day.
+// It wraps around midnight when End is before Start.
+type Window struct {
+	Start int
+	End   int
+}
+
+// Parse returns the minute of the day of a HH:MM time
+func Parse(value string) (int, error) {
+	t, err := time.Parse(Layout, value)
+	if err != nil {
+		return 0, fmt.Errorf("time of day must be in HH:MM, but got %q", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ParseWindow returns the window between two HH:MM times
+func ParseWindow(start string, end string) (Window, error) {
+	var window Window
+	var err error
+	if window.Start, err = Parse(start); err != nil {
+		return Window{}, err
+	}
+	if window.End, err = Parse(end); err != nil {
+		return Window{}, err
+	}
+	return window, nil
+}
+
+// Contains returns true if the time of day of t is within the window
+func (window Window) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if window.Start <= window.End {
+		return minute >= window.Start && minute < window.End
+	}
+	return minute >= window.Start || minute < window.End
+}
@@ -0,0 +1,54 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package timeofday
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowContains(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		end   string
+		time  string
+		want  bool
+	}{
+		{"within", "09:00", "17:00", "12:30", true},
+		{"at start", "09:00", "17:00", "09:00", true},
+		{"at end", "09:00", "17:00", "17:00", false},
+		{"before", "09:00", "17:00", "08:59", false},
+		{"wrapping before midnight", "22:00", "06:00", "23:15", true},
+		{"wrapping after midnight", "22:00", "06:00", "05:59", true},
+		{"wrapping outside", "22:00", "06:00", "12:00", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			window, err := ParseWindow(test.start, test.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			at, err := time.Parse(Layout, test.time)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := window.Contains(at); got != test.want {
+				t.Errorf("expected %s in %s-%s to be %v, but got %v", test.time, test.start, test.end, test.want, got)
+			}
+		})
+	}
+}
+
+func TestParseWindowInvalid(t *testing.T) {
+	for _, times := range [][2]string{{"", "06:00"}, {"22:00", "6pm"}, {"24:00", "06:00"}} {
+		if _, err := ParseWindow(times[0], times[1]); err == nil {
+			t.Errorf("expected %s-%s to be invalid", times[0], times[1])
+		}
+	}
+}
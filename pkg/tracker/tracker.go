@@ -0,0 +1,192 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package tracker follows detected objects from frame to frame, so that the same object keeps the same
+// track ID for as long as it stays in view.
+package tracker
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// Detection is an object found in a single frame
+type Detection struct {
+	Label      string
+	Confidence float64
+	Rect       image.Rectangle
+}
+
+// Box is a bounding box in pixels
+type Box struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// NewBox converts a rectangle into a Box
+func NewBox(rect image.Rectangle) Box {
+	return Box{X: rect.Min.X, Y: rect.Min.Y, Width: rect.Dx(), Height: rect.Dy()}
+}
+
+// Rect converts a Box back into a rectangle
+func (box Box) Rect() image.Rectangle {
+	return image.Rect(box.X, box.Y, box.X+box.Width, box.Y+box.Height)
+}
+
+// Position is where a tracked object was detected in a single frame
+type Position struct {
+	Frame      int     `json:"frame"`
+	Box        Box     `json:"box"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Track is a single object followed across frames
+type Track struct {
+	ID         int    `json:"id"`
+	Label      string `json:"label"`
+	FirstFrame int    `json:"first_frame"`
+	LastFrame  int    `json:"last_frame"`
+	// Every frame the object was detected in, oldest first
+	Path []Position `json:"path"`
+
+	// frames in a row the object was not detected in
+	missed int
+}
+
+// Tracker assigns the detections of each frame to tracks. Detections are matched to the active track of
+// the same label they overlap the most, or failing that, whose last box they are closest to.
+type Tracker struct {
+	// a detection continues a track if it overlaps the last box of the track by at least minOverlap
+	// (intersection over union), or if their centers are within maxDistance of the last box's diagonal
+	minOverlap  float64
+	maxDistance float64
+	// a track ends once its object is not detected for more than maxMissed frames in a row
+	maxMissed int
+
+	nextID int
+	active []*Track
+	tracks []*Track
+}
+
+// New creates a tracker. maxDistance is a fraction of the diagonal of a track's last box, such as 0.5.
+func New(minOverlap float64, maxDistance float64, maxMissed int) *Tracker {
+	return &Tracker{
+		minOverlap:  minOverlap,
+		maxDistance: maxDistance,
+		maxMissed:   maxMissed,
+		nextID:      1,
+	}
+}
+
+// candidate is a possible match between an active track and a detection
+type candidate struct {
+	track, detection int
+	overlap          float64
+	distance         float64
+}
+
+// Update assigns the detections of a frame to tracks, starting new tracks for unmatched detections.
+// The track of each detection is returned in the same order as the detections. Frames must be
+// updated in increasing order, including frames without any detections.
+func (tracker *Tracker) Update(frame int, detections []Detection) []*Track {
+	var candidates []candidate
+	for t, track := range tracker.active {
+		last := track.Path[len(track.Path)-1].Box.Rect()
+		for d, detection := range detections {
+			if detection.Label != track.Label {
+				continue
+			}
+			c := candidate{
+				track:     t,
+				detection: d,
+				overlap:   IoU(last, detection.Rect),
+				distance:  centerDistance(last, detection.Rect) / diagonal(last),
+			}
+			if c.overlap >= tracker.minOverlap || c.distance <= tracker.maxDistance {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+	// best overlap first, and the closest of those which do not overlap
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].overlap != candidates[j].overlap {
+			return candidates[i].overlap > candidates[j].overlap
+		}
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	assigned := make([]*Track, len(detections))
+	matched := make(map[int]bool)
+	for _, c := range candidates {
+		if matched[c.track] || assigned[c.detection] != nil {
+			continue
+		}
+		matched[c.track] = true
+		assigned[c.detection] = tracker.active[c.track]
+	}
+
+	active := tracker.active[:0]
+	for t, track := range tracker.active {
+		if !matched[t] {
+			track.missed++
+			if track.missed > tracker.maxMissed {
+				continue
+			}
+		}
+		active = append(active, track)
+	}
+	tracker.active = active
+
+	for d, detection := range detections {
+		track := assigned[d]
+		if track == nil {
+			track = &Track{ID: tracker.nextID, Label: detection.Label, FirstFrame: frame}
+			tracker.nextID++
+			tracker.active = append(tracker.active, track)
+			tracker.tracks = append(tracker.tracks, track)
+			assigned[d] = track
+		}
+		track.missed = 0
+		track.LastFrame = frame
+		track.Path = append(track.Path, Position{Frame: frame, Box: NewBox(detection.Rect), Confidence: detection.Confidence})
+	}
+	return assigned
+}
+
+// Tracks returns every track so far, ordered by ID
+func (tracker *Tracker) Tracks() []Track {
+	tracks := make([]Track, 0, len(tracker.tracks))
+	for _, track := range tracker.tracks {
+		tracks = append(tracks, *track)
+	}
+	return tracks
+}
+
+// IoU returns the intersection over union of two rectangles, from 0 (not overlapping) to 1 (the same rectangle)
+func IoU(a image.Rectangle, b image.Rectangle) float64 {
+	intersection := a.Intersect(b)
+	if intersection.Empty() {
+		return 0
+	}
+	return area(intersection) / (area(a) + area(b) - area(intersection))
+}
+
+func area(rect image.Rectangle) float64 {
+	return float64(rect.Dx() * rect.Dy())
+}
+
+func centerDistance(a image.Rectangle, b image.Rectangle) float64 {
+	dx := float64(a.Min.X+a.Max.X-b.Min.X-b.Max.X) / 2
+	dy := float64(a.Min.Y+a.Max.Y-b.Min.Y-b.Max.Y) / 2
+	return math.Hypot(dx, dy)
+}
+
+func diagonal(rect image.Rectangle) float64 {
+	return math.Max(math.Hypot(float64(rect.Dx()), float64(rect.Dy())), 1)
+}
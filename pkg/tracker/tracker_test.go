@@ -0,0 +1,95 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tracker
+
+import (
+	"image"
+	"testing"
+)
+
+func face(x int, y int) Detection {
+	return Detection{Label: "face", Confidence: 1, Rect: image.Rect(x, y, x+100, y+100)}
+}
+
+func TestUpdate(t *testing.T) {
+	tracker := New(0.3, 0.5, 2)
+
+	// two faces walking right, one of them disappearing for a frame
+	frames := [][]Detection{
+		{face(0, 0), face(500, 0)},
+		{face(10, 0), face(510, 0)},
+		{face(20, 0)},
+		{face(30, 0), face(530, 0)},
+		// jumps too far to overlap, but its center is still close
+		{face(90, 0), face(540, 0)},
+	}
+	for frame, detections := range frames {
+		assigned := tracker.Update(frame, detections)
+		for i, track := range assigned {
+			if track == nil {
+				t.Fatalf("frame %d: detection %d was not assigned a track", frame, i)
+			}
+			if expected := i + 1; track.ID != expected {
+				t.Errorf("frame %d: expected detection %d to be track %d, but got %d", frame, i, expected, track.ID)
+			}
+		}
+	}
+
+	tracks := tracker.Tracks()
+	if len(tracks) != 2 {
+		t.Fatalf("expected 2 tracks, but got %d", len(tracks))
+	}
+	if tracks[0].FirstFrame != 0 || tracks[0].LastFrame != 4 || len(tracks[0].Path) != 5 {
+		t.Errorf("unexpected first track: %+v", tracks[0])
+	}
+	if tracks[1].FirstFrame != 0 || tracks[1].LastFrame != 4 || len(tracks[1].Path) != 4 {
+		t.Errorf("unexpected second track: %+v", tracks[1])
+	}
+	if box := tracks[0].Path[4].Box; box != (Box{X: 90, Y: 0, Width: 100, Height: 100}) {
+		t.Errorf("unexpected last box of the first track: %+v", box)
+	}
+}
+
+func TestUpdateNewTracks(t *testing.T) {
+	tracker := New(0.3, 0.5, 1)
+
+	tracker.Update(0, []Detection{face(0, 0)})
+	// a different label never continues a track
+	body := Detection{Label: "full_body", Rect: image.Rect(0, 0, 100, 100)}
+	if assigned := tracker.Update(1, []Detection{body}); assigned[0].ID != 2 {
+		t.Errorf("expected a new track for a different label, but got track %d", assigned[0].ID)
+	}
+	// far away from the first face
+	if assigned := tracker.Update(2, []Detection{face(800, 600)}); assigned[0].ID != 3 {
+		t.Errorf("expected a new track for a distant face, but got track %d", assigned[0].ID)
+	}
+	// the first face was missed for two frames, so its track has ended
+	tracker.Update(3, nil)
+	if assigned := tracker.Update(4, []Detection{face(0, 0)}); assigned[0].ID != 4 {
+		t.Errorf("expected a new track after the first one ended, but got track %d", assigned[0].ID)
+	}
+
+	if tracks := tracker.Tracks(); len(tracks) != 4 {
+		t.Errorf("expected 4 tracks, but got %d", len(tracks))
+	}
+}
+
+func TestIoU(t *testing.T) {
+	tests := []struct {
+		a, b     image.Rectangle
+		expected float64
+	}{
+		{image.Rect(0, 0, 10, 10), image.Rect(0, 0, 10, 10), 1},
+		{image.Rect(0, 0, 10, 10), image.Rect(20, 20, 30, 30), 0},
+		{image.Rect(0, 0, 10, 10), image.Rect(5, 0, 15, 10), 50.0 / 150.0},
+	}
+	for _, test := range tests {
+		if iou := IoU(test.a, test.b); iou != test.expected {
+			t.Errorf("IoU(%v, %v): expected %v, but got %v", test.a, test.b, test.expected, iou)
+		}
+	}
+}
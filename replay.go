@@ -0,0 +1,316 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/edgexfoundry/app-functions-sdk-go/appcontext"
+	"github.com/edgexfoundry/go-mod-core-contracts/models"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/incident"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/lossprevention"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/camera"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/clock"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	replayDevice = "replay"
+	// longest line of a replay file, as inventory events with many tags can be large
+	maxReplayLine = 16 * 1024 * 1024
+)
+
+// capturedReading is a single line of a replay file
+type capturedReading struct {
+	// Time the reading was originally received in milliseconds epoch
+	Timestamp int64  `json:"timestamp"`
+	Name      string `json:"name"`
+	// Either the reading value as stored by EdgeX (a JSON string), or the JSON-RPC message itself
+	Value json.RawMessage `json:"value"`
+}
+
+func (reading *capturedReading) value() (string, error) {
+	if len(reading.Value) > 0 && reading.Value[0] == '"' {
+		var value string
+		err := json.Unmarshal(reading.Value, &value)
+		return value, err
+	}
+	return string(reading.Value), nil
+}
+
+// replayReport is every incident a replay would have generated
+type replayReport struct {
+	File     string `json:"file"`
+	Readings int    `json:"readings"`
+	// Number of lines which could not be parsed, and readings which could not be handled
+	Failed int `json:"failed"`
+	// Time of the first and last replayed readings in milliseconds epoch
+	Start     int64                `json:"start"`
+	End       int64                `json:"end"`
+	Incidents []*incident.Incident `json:"incidents"`
+}
+
+// replayRecorder stands in for the cameras during a replay. A recording takes as long on the replay clock as it
+// would live, and can be extended while it lasts, so that the extend and queue recording modes behave as they
+// would live, but nothing is recorded.
+type replayRecorder struct {
+	mutex sync.Mutex
+	// end of the last recording of each camera in milliseconds epoch, which is in progress until the clock reaches it
+	ends map[string]int64
+	// latest end of the recording in progress of each camera, 0 if it has no limit
+	limits map[string]int64
+	// cameras whose recording is waiting for the clock to reach its end
+	waiting map[string]bool
+}
+
+func newReplayRecorder() *replayRecorder {
+	return &replayRecorder{
+		ends:    make(map[string]int64),
+		limits:  make(map[string]int64),
+		waiting: make(map[string]bool),
+	}
+}
+
+func (recorder *replayRecorder) Record(cam *camera.Camera, triggeredOn int64, seconds float64, folderName string) (*camera.Metadata, error) {
+	recorder.mutex.Lock()
+	// as on the camera, a recording only starts once the previous one is done
+	start := triggeredOn
+	if end := recorder.ends[cam.Name]; end > start {
+		start = end
+	}
+	recorder.ends[cam.Name] = start + int64(seconds*1000)
+	recorder.limits[cam.Name] = 0
+	if config.AppConfig.MaxRecordingDuration > 0 {
+		recorder.limits[cam.Name] = start + int64(config.AppConfig.MaxRecordingDuration)*1000
+	}
+	recorder.mutex.Unlock()
+
+	for recorder.wait(cam.Name) {
+	}
+
+	return &camera.Metadata{
+		Camera:      cam.Name,
+		VideoDevice: cam.VideoDevice,
+		FPS:         float64(config.AppConfig.VideoOutputFps),
+		TriggeredOn: triggeredOn,
+	}, nil
+}
+
+// wait blocks until the clock reaches the end of the recording of the camera. It returns false if the
+// recording was already over.
+func (recorder *replayRecorder) wait(cameraName string) bool {
+	recorder.mutex.Lock()
+	remaining := recorder.ends[cameraName] - clock.Now()
+	if remaining <= 0 {
+		recorder.mutex.Unlock()
+		return false
+	}
+
+	done := make(chan struct{})
+	recorder.waiting[cameraName] = true
+	clock.AfterFunc(time.Duration(remaining)*time.Millisecond, func() {
+		recorder.mutex.Lock()
+		recorder.waiting[cameraName] = false
+		recorder.mutex.Unlock()
+		close(done)
+	})
+	recorder.mutex.Unlock()
+
+	<-done
+	return true
+}
+
+func (recorder *replayRecorder) Extend(cam *camera.Camera, seconds float64) bool {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	now, end := clock.Now(), recorder.ends[cam.Name]
+	if now >= end {
+		return false
+	}
+	extended := now + int64(seconds*1000)
+	if limit := recorder.limits[cam.Name]; limit > 0 && extended > limit {
+		logrus.Warnf("unable to extend recording on camera %s past the max recording duration", cam.Name)
+		extended = limit
+	}
+	if extended > end {
+		recorder.ends[cam.Name] = extended
+	}
+	return true
+}
+
+// settled returns true once every camera is either done with its incidents, or waiting for the clock to reach
+// the end of its recording. Until then, a camera may still be starting or finishing a recording.
+func (recorder *replayRecorder) settled() bool {
+	for _, cam := range camera.All() {
+		recorder.mutex.Lock()
+		waiting := recorder.waiting[cam.Name]
+		recorder.mutex.Unlock()
+
+		if !waiting && lossprevention.UnfinishedRecordings(cam.Name) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// settle waits for every camera to settle, so that the outcome of a replay does not depend on how fast the
+// cameras start and finish recordings compared to how fast readings are replayed
+func (recorder *replayRecorder) settle() {
+	for !recorder.settled() {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// recording returns true while any camera has incidents left to record
+func (recorder *replayRecorder) recording() bool {
+	for _, cam := range camera.All() {
+		if lossprevention.UnfinishedRecordings(cam.Name) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// advance moves the replay clock to the given time, letting the cameras settle after each timer that fires
+func (recorder *replayRecorder) advance(fake *clock.Fake, to int64) {
+	for fake.Step(to) {
+		recorder.settle()
+	}
+	fake.Advance(to)
+}
+
+// replay feeds every captured reading of a file through processEvents, as if each reading was received at its
+// original time, and writes a report of every incident that was generated. The cameras are replaced by
+// recordings which take as long as they would live on the replay clock but record nothing, incidents are
+// stored in a temporary database, and they are added to the report instead of being notified.
+// speed is how many times faster than their original timing the readings are fed, 0 feeds them as fast as
+// possible. Since every timer runs on the replay clock, the speed does not change the report.
+func replay(filename string, speed float64, out io.Writer) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	folder, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		return errors.Wrap(err, "unable to create the replay folder")
+	}
+	defer os.RemoveAll(folder)
+
+	if err := incident.Open(filepath.Join(folder, "incidents.db")); err != nil {
+		return err
+	}
+	defer incident.Close()
+
+	report := &replayReport{File: filename, Incidents: []*incident.Incident{}}
+	var reportMutex sync.Mutex
+	lossprevention.OnIncident(func(inc *incident.Incident) {
+		reportMutex.Lock()
+		report.Incidents = append(report.Incidents, inc)
+		reportMutex.Unlock()
+	})
+	defer lossprevention.OnIncident(nil)
+
+	recorder := newReplayRecorder()
+	lossprevention.SetRecorder(recorder, filepath.Join(folder, "recordings"))
+	defer lossprevention.SetRecorder(nil, "")
+
+	// the replay clock starts at the first reading
+	var fake *clock.Fake
+	stopWatching := func() {}
+	defer func() {
+		stopWatching()
+		clock.Set(nil)
+	}()
+
+	edgexcontext := &appcontext.Context{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxReplayLine)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var reading capturedReading
+		if err := json.Unmarshal(scanner.Bytes(), &reading); err != nil {
+			logrus.Warnf("skipping line %d of %s: %v", line, filename, err)
+			report.Failed++
+			continue
+		}
+		value, err := reading.value()
+		if err != nil {
+			logrus.Warnf("skipping line %d of %s: invalid value: %v", line, filename, err)
+			report.Failed++
+			continue
+		}
+
+		if fake == nil {
+			fake = clock.NewFake(reading.Timestamp)
+			clock.Set(fake)
+			stopWatching = lossprevention.WatchFittingRooms()
+		}
+		if previous := fake.Now(); speed > 0 && reading.Timestamp > previous {
+			time.Sleep(time.Duration(float64(reading.Timestamp-previous) / speed * float64(time.Millisecond)))
+		}
+		recorder.advance(fake, reading.Timestamp)
+		if report.Start == 0 {
+			report.Start = reading.Timestamp
+		}
+		report.End = reading.Timestamp
+		report.Readings++
+
+		event := models.Event{
+			Device: replayDevice,
+			Origin: reading.Timestamp,
+			Readings: []models.Reading{{
+				Device: replayDevice,
+				Origin: reading.Timestamp,
+				Name:   reading.Name,
+				Value:  value,
+			}},
+		}
+		if _, result := processEvents(edgexcontext, event); result != nil {
+			logrus.Warnf("unable to handle %s reading on line %d of %s: %v", reading.Name, line, filename, result)
+			report.Failed++
+		}
+		recorder.settle()
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrapf(err, "unable to read %s", filename)
+	}
+
+	if fake != nil {
+		// events whose remaining segments were never captured are evaluated as if they timed out
+		lossprevention.FlushSegments()
+		recorder.settle()
+
+		// the recordings still in progress end when they would have live
+		for recorder.recording() && fake.Step(math.MaxInt64) {
+			recorder.settle()
+		}
+	}
+
+	reportMutex.Lock()
+	defer reportMutex.Unlock()
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -0,0 +1,150 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/app/rules"
+	"github.com/intel/rsp-sw-toolkit-im-suite-loss-prevention-service/pkg/camera"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCapturedReadingValue(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"edgex string", `{"timestamp": 1, "name": "inventory_event", "value": "{\"jsonrpc\":\"2.0\"}"}`, `{"jsonrpc":"2.0"}`},
+		{"raw message", `{"timestamp": 1, "name": "inventory_event", "value": {"jsonrpc":"2.0"}}`, `{"jsonrpc":"2.0"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var reading capturedReading
+			if err := json.Unmarshal([]byte(test.line), &reading); err != nil {
+				t.Fatal(err)
+			}
+			value, err := reading.value()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != test.want {
+				t.Errorf("expected %s, but got %s", test.want, value)
+			}
+		})
+	}
+}
+
+const replayStart = 1571234560000
+
+func sensorConfigLine(timestamp int64, deviceId string, personality string) string {
+	return fmt.Sprintf(`{"timestamp": %d, "name": "sensor_config_notification", "value": {"jsonrpc": "2.0", "method": "sensor_config_notification", "params": {"device_id": "%s", "facility_id": "store", "personality": "%s", "aliases": ["%s-0"]}}}`,
+		timestamp, deviceId, personality, deviceId)
+}
+
+// movedToExitLine is an inventory event of a tag which moved from the sales floor to the exit a second ago
+func movedToExitLine(timestamp int64, epc string, segment int, segments int) string {
+	return fmt.Sprintf(`{"timestamp": %d, "name": "inventory_event", "value": {"device_id": "gateway", "sent_on": %d, "event_segment_number": %d, "total_event_segments": %d, "data": [{"epc": "%s", "product_id": "900382", "facility_id": "store", "event": "moved", "location_history": [{"location": "RSP-EXIT-0", "timestamp": %d}, {"location": "RSP-FLOOR-0", "timestamp": %d}]}]}}`,
+		timestamp, timestamp, segment, segments, epc, timestamp-1000, timestamp-5000)
+}
+
+func TestReplay(t *testing.T) {
+	saved := config.AppConfig
+	defer func() {
+		config.AppConfig = saved
+		camera.SetupCameras()
+		if err := rules.SetupRules(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	config.AppConfig.Rules = config.DefaultRules
+	config.AppConfig.Cameras = []config.CameraConfig{{Name: "front"}}
+	config.AppConfig.RecordingMode = config.RecordingModeExtend
+	config.AppConfig.RecordingDuration = 10
+	config.AppConfig.MaxRecordingDuration = 60
+	config.AppConfig.RecordingQueueSize = 5
+	config.AppConfig.SegmentTimeout = 5
+	config.AppConfig.MaxTransitionTime = 30
+	config.AppConfig.VideoOutputFps = 25
+	config.AppConfig.EPCFilterRegex = regexp.MustCompile(".*")
+	config.AppConfig.SKUFilterRegex = regexp.MustCompile(".*")
+	config.AppConfig.GTINFilterRegex = regexp.MustCompile(".*")
+	camera.SetupCameras()
+	if err := rules.SetupRules(); err != nil {
+		t.Fatal(err)
+	}
+
+	capture := []string{
+		sensorConfigLine(replayStart, "RSP-FLOOR", "NONE"),
+		sensorConfigLine(replayStart, "RSP-EXIT", "EXIT"),
+		// recorded from 10s to 20s, and extended until 25s by the second tag
+		movedToExitLine(replayStart+10000, "AA01", 1, 1),
+		movedToExitLine(replayStart+15000, "AA02", 1, 1),
+		// after the first recording is over, so recorded on its own
+		movedToExitLine(replayStart+40000, "AA03", 1, 1),
+		// the second segment never arrives, so the event is evaluated once the segment timeout fires at 55s
+		movedToExitLine(replayStart+50000, "AA04", 1, 2),
+		sensorConfigLine(replayStart+70000, "RSP-EXIT", "EXIT"),
+	}
+
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "capture.jsonl")
+	if err := ioutil.WriteFile(filename, []byte(strings.Join(capture, "\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := replay(filename, 0, &out); err != nil {
+		t.Fatal(err)
+	}
+	var report replayReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Readings != len(capture) || report.Failed != 0 {
+		t.Errorf("expected %d readings without failures, but got %d readings and %d failures", len(capture), report.Readings, report.Failed)
+	}
+	expected := []struct {
+		timestamp int64
+		epcs      []string
+	}{
+		{replayStart + 10000, []string{"AA01", "AA02"}},
+		{replayStart + 40000, []string{"AA03"}},
+		{replayStart + 55000, []string{"AA04"}},
+	}
+	if len(report.Incidents) != len(expected) {
+		t.Fatalf("expected %d incidents, but got %+v", len(expected), report.Incidents)
+	}
+	for i, inc := range report.Incidents {
+		var epcs []string
+		for _, tag := range inc.Tags {
+			epcs = append(epcs, tag.EPC)
+		}
+		if inc.Timestamp != expected[i].timestamp || !reflect.DeepEqual(epcs, expected[i].epcs) {
+			t.Errorf("expected incident %d at %d with %v, but got %d with %v", i, expected[i].timestamp, expected[i].epcs, inc.Timestamp, epcs)
+		}
+		if inc.Camera != "front" || len(inc.Recordings) != 1 {
+			t.Errorf("expected incident %d to be recorded once on the front camera, but got %s with recordings %v", i, inc.Camera, inc.Recordings)
+		}
+	}
+}